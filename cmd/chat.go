@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/andrew-candela/peppermint/internal"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCMD.AddCommand(chatCommand)
+}
+
+var chatCommand = &cobra.Command{
+	Use:   "chat",
+	Short: "Read and write messages for a group in one terminal.",
+	Long: `
+	Opens a single authenticated websocket session with the server.
+	Messages sent to the group are printed above the prompt,
+	and messages you write are published over the same connection.
+	`,
+	PreRun: configureLogger,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := internal.ParseConfigWithViper(group)
+		internal.MessageEntrypoint(internal.CHAT, config)
+	},
+}
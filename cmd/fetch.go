@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/andrew-candela/peppermint/internal"
+	"github.com/spf13/cobra"
+)
+
+var fetch_output string
+
+func init() {
+	fetchCommand.Flags().StringVarP(&fetch_output, "output", "o", "", "Where to save the file, instead of its own name in this directory")
+	rootCMD.AddCommand(fetchCommand)
+}
+
+var fetchCommand = &cobra.Command{
+	Use:   "fetch <id>",
+	Short: "Download a file someone sent you.",
+	Long: `
+	Downloads the file with the ID the reader showed when it was sent,
+	decrypts it and checks it against the hash the sender gave.
+	A download that is cut off carries on where it stopped.
+	`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: configureLogger,
+	Run: func(cmd *cobra.Command, args []string) {
+		internal.FetchFile(args[0], fetch_output)
+	},
+}
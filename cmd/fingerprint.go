@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/andrew-candela/peppermint/internal"
+	"github.com/spf13/cobra"
+)
+
+var show_qr bool
+
+func init() {
+	fingerprintCommand.Flags().BoolVar(&show_qr, "qr", false, "Also draw the fingerprint as a QR code")
+	rootCMD.AddCommand(fingerprintCommand)
+}
+
+var fingerprintCommand = &cobra.Command{
+	Use:   "fingerprint",
+	Short: "Show the fingerprint of your key.",
+	Long: `
+	Prints a short fingerprint of your public key,
+	for friends to check against the key in their config.
+	`,
+	PreRun: configureLogger,
+	Run: func(cmd *cobra.Command, args []string) {
+		internal.ShowFingerprint(show_qr)
+	},
+}
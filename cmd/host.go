@@ -3,7 +3,6 @@ package cmd
 import (
 	"github.com/andrew-candela/peppermint/internal"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 func init() {
@@ -16,7 +15,6 @@ var hostCommand = &cobra.Command{
 	Long:   "Host a webserver that forwards messages via a websocket connection to group members.",
 	PreRun: configureLogger,
 	Run: func(cmd *cobra.Command, args []string) {
-		internal.ParseConfig()
-		internal.HostWeb(viper.GetString("port"))
+		internal.HostWeb(internal.ParseHostConfig())
 	},
 }
@@ -10,13 +10,18 @@ var initCmd = &cobra.Command{
 	Short: "Create a config file for peppermint",
 	Long: `Creates the ~/.peppermint/ directory, with a few files:
 	config: a toml file
-	peppermint_id_rsa: a randomly generated RSA private key file in PKCS #1, ASN.1 DER form.`,
+	id_rsa: a randomly generated RSA private key file in PKCS #1, ASN.1 DER form.
+	or id_ed25519: a randomly generated Ed25519 private key file in PKCS #8 form,
+	with --key-type ed25519.`,
 	PreRun: configureLogger,
 	Run: func(cmd *cobra.Command, args []string) {
-		internal.InitPPMT()
+		internal.InitPPMT(key_type)
 	},
 }
 
+var key_type string
+
 func init() {
+	initCmd.Flags().StringVar(&key_type, "key-type", internal.KEY_TYPE_RSA, "Type of key to generate: rsa or ed25519")
 	rootCMD.AddCommand(initCmd)
 }
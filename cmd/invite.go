@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/andrew-candela/peppermint/internal"
+	"github.com/spf13/cobra"
+)
+
+var invite_name string
+var invite_ttl time.Duration
+
+func init() {
+	inviteCommand.Flags().StringVar(&invite_name, "name", internal.DefaultName(), "What the people you invite will call you")
+	inviteCommand.Flags().DurationVar(&invite_ttl, "expires", internal.DEFAULT_INVITE_TTL, "How long the invite can be used for")
+	rootCMD.AddCommand(inviteCommand)
+}
+
+var inviteCommand = &cobra.Command{
+	Use:   "invite",
+	Short: "Print an invite token for a group.",
+	Long: `
+	Prints a signed token with the group's server, its name and ID,
+	and your public key. Whoever runs 'peppermint join' with it gets
+	the group and you added to their config, and their key is sent
+	back to you. Keep 'peppermint read' running to add them to yours.
+	`,
+	PreRun: configureLogger,
+	Run: func(cmd *cobra.Command, args []string) {
+		internal.InviteToGroup(group, invite_name, invite_ttl)
+	},
+}
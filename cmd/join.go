@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/andrew-candela/peppermint/internal"
+	"github.com/spf13/cobra"
+)
+
+var join_name string
+
+func init() {
+	joinCommand.Flags().StringVar(&join_name, "name", internal.DefaultName(), "What the person who invited you will call you")
+	rootCMD.AddCommand(joinCommand)
+}
+
+var joinCommand = &cobra.Command{
+	Use:   "join <token>",
+	Short: "Join a group with an invite token.",
+	Long: `
+	Checks the invite, adds its group and the person who made it
+	to your config, and sends them your public key over the server.
+	The group is named as the inviter named it, unless -g is given.
+	`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: configureLogger,
+	Run: func(cmd *cobra.Command, args []string) {
+		internal.JoinGroup(args[0], group, join_name)
+	},
+}
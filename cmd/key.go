@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/andrew-candela/peppermint/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var key_file string
+
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manage your private key file",
+}
+
+var passwdCmd = &cobra.Command{
+	Use:   "passwd",
+	Short: "Add, change or remove the passphrase on your private key",
+	Long: `Encrypts the private key file with a new passphrase,
+	or stores it unencrypted if the new passphrase is empty.
+	Asks for the current passphrase first if the key is already encrypted.
+	Uses private_key_file from the config unless --key-file is given.`,
+	PreRun: configureLogger,
+	Run: func(cmd *cobra.Command, args []string) {
+		if key_file == "" {
+			internal.ParseConfig()
+			key_file = viper.GetString("private_key_file")
+		}
+		if key_file == "" {
+			fmt.Println("No private_key_file in the config, pass one with --key-file")
+			os.Exit(1)
+		}
+		internal.ChangePassphrase(key_file)
+	},
+}
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Replace your private key with a new one",
+	Long: `Makes a new private key of the same type, and sends everyone in
+	every group in the config a statement signed by the old and new keys.
+	Their readers swap your key in their config when it arrives.
+	The old key is kept next to the new one with .old on the end.`,
+	PreRun: configureLogger,
+	Run: func(cmd *cobra.Command, args []string) {
+		internal.RotateKey()
+	},
+}
+
+func init() {
+	passwdCmd.Flags().StringVar(&key_file, "key-file", "", "Private key file to change the passphrase of")
+	keyCmd.AddCommand(passwdCmd)
+	keyCmd.AddCommand(rotateCmd)
+	rootCMD.AddCommand(keyCmd)
+}
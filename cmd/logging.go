@@ -24,7 +24,8 @@ func configureLogger(cmd *cobra.Command, args []string) {
 	log_options := &slog.HandlerOptions{
 		Level: level,
 	}
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, log_options))
+	// stderr keeps logs out of what commands print, like 'read --format jsonl'
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, log_options))
 	slog.SetDefault(logger)
 	slog.Debug("Debug output is enabled!")
 }
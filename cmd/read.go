@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/andrew-candela/peppermint/internal"
 	"github.com/spf13/cobra"
 )
 
+var read_format string
+
 func init() {
+	readCommand.Flags().StringVar(&read_format, "format", "text", "How to print what is read: text, or jsonl for one JSON object per line")
 	rootCMD.AddCommand(readCommand)
 }
 
@@ -15,10 +21,21 @@ var readCommand = &cobra.Command{
 	Long: `
 	Listens for messages sent to the specified group.
 	Prints the group messages into stdOut.
+	With '--format jsonl' each message, error and change in the
+	connection is written as a line of JSON instead, for other programs.
 	`,
 	PreRun: configureLogger,
 	Run: func(cmd *cobra.Command, args []string) {
+		action := internal.READ
+		switch read_format {
+		case "text":
+		case "jsonl":
+			action = internal.READ_JSONL
+		default:
+			fmt.Println("Unknown format", read_format, "... use text or jsonl")
+			os.Exit(1)
+		}
 		config := internal.ParseConfigWithViper(group)
-		internal.MessageEntrypoint(internal.READ, config)
+		internal.MessageEntrypoint(action, config)
 	},
 }
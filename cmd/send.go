@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/andrew-candela/peppermint/internal"
+	"github.com/spf13/cobra"
+)
+
+var send_whole bool
+var send_quiet bool
+
+func init() {
+	sendCommand.Flags().BoolVar(&send_whole, "whole", false, "Send everything read from stdin as one message, instead of a message per line")
+	sendCommand.Flags().BoolVarP(&send_quiet, "quiet", "q", false, "Only print the recipients a message couldn't be sent to")
+	rootCMD.AddCommand(sendCommand)
+}
+
+var sendCommand = &cobra.Command{
+	Use:   "send [message]",
+	Short: "Send a message to a group without a prompt.",
+	Long: `
+	Sends the message given as arguments and exits, for scripts and
+	cron jobs. With no message, stdin is read instead and each line
+	is sent as its own message, or all of it as one with --whole.
+	Exits with 2 if any recipient couldn't be sent a message.
+	`,
+	PreRun: configureLogger,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := internal.ParseConfigWithViper(group)
+		internal.SendToGroup(config, strings.Join(args, " "), send_whole, send_quiet)
+	},
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/andrew-candela/peppermint/internal"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCMD.AddCommand(sendFileCommand)
+}
+
+var sendFileCommand = &cobra.Command{
+	Use:   "send-file <path>",
+	Short: "Send a file to a group.",
+	Long: `
+	Encrypts the file with a new key and uploads it to the relay,
+	then tells the group how to fetch it. If the upload is cut off,
+	sending the same file again carries on where it stopped.
+	The writer does the same with '/file <path>'.
+	`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: configureLogger,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := internal.ParseConfigWithViper(group)
+		internal.SendFileToGroup(config, args[0])
+	},
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/andrew-candela/peppermint/internal"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCMD.AddCommand(tuiCommand)
+}
+
+var tuiCommand = &cobra.Command{
+	Use:   "tui",
+	Short: "Read and write to all your groups in a full-screen UI.",
+	Long: `
+	Like chat, but full-screen. The group given with --group is shown
+	first, and every other group on the same server is in the sidebar
+	with its unread count, along with who in the group is online.
+	Tab and Shift-Tab switch groups, PgUp and PgDn scroll back,
+	'/file <path>' sends a file and Ctrl-C quits.
+	`,
+	PreRun: configureLogger,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := internal.ParseConfigWithViper(group)
+		internal.MessageEntrypoint(internal.TUI_MODE, config)
+	},
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/andrew-candela/peppermint/internal"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	verifyCommand.Flags().BoolVar(&show_qr, "qr", false, "Also draw each safety number as a QR code")
+	rootCMD.AddCommand(verifyCommand)
+}
+
+var verifyCommand = &cobra.Command{
+	Use:   "verify [name]",
+	Short: "Compare safety numbers with the users in a group.",
+	Long: `
+	Prints the safety number you share with each user in the group,
+	or just the named user. Read it out to them in person or on a call;
+	if they see the same number, nobody has swapped your keys.
+	Given a name, asks whether the numbers matched and records
+	the user as verified, which the reader shows next to their messages.
+	`,
+	Args:   cobra.MaximumNArgs(1),
+	PreRun: configureLogger,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		internal.VerifyContacts(group, name, show_qr)
+	},
+}
@@ -16,6 +16,7 @@ var writeCommand = &cobra.Command{
 	Instantiates a Writer and starts a readline loop.
 	Each message is signed, encrypted and then sent to its
 	intended recipient.
+	Send a file with '/file <path>'.
 	`,
 	PreRun: configureLogger,
 	Run: func(cmd *cobra.Command, args []string) {
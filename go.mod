@@ -6,10 +6,11 @@ require (
 	github.com/chzyer/readline v1.5.1
 	github.com/spf13/cobra v1.7.0
 	github.com/spf13/viper v1.16.0
-	golang.org/x/crypto v0.13.0
+	golang.org/x/crypto v0.14.0
 	golang.org/x/term v0.13.0
 	google.golang.org/protobuf v1.31.0
 	nhooyr.io/websocket v1.8.7
+	rsc.io/qr v0.2.0
 )
 
 require (
@@ -0,0 +1,86 @@
+/*
+Access control for the relay server.
+
+The host config can list groups along with the public keys allowed
+in each one. Once any groups are listed, only those keys can subscribe
+or publish, and a message is only relayed to a recipient who shares a
+group with the sender. Admins of a group are members of it, and may
+also publish to every key the relay knows about.
+
+Without any groups the relay is open to anyone who can sign a request.
+*/
+
+package internal
+
+import "fmt"
+
+// A group as written in the host config. Keys are PEM encoded.
+type AccessGroupConfig struct {
+	Name    string
+	Members []string
+	Admins  []string
+}
+
+// Keys are stored in the same hex form used in the request headers.
+type AccessList struct {
+	// the names of the groups each key belongs to
+	groups map[string]map[string]bool
+	admins map[string]bool
+}
+
+// Builds the access list from the host config.
+// Returns nil when no groups are configured, which leaves the relay open.
+func NewAccessList(group_configs []AccessGroupConfig) (*AccessList, error) {
+	if len(group_configs) == 0 {
+		return nil, nil
+	}
+	acl := &AccessList{
+		groups: map[string]map[string]bool{},
+		admins: map[string]bool{},
+	}
+	for _, group := range group_configs {
+		for _, pem_key := range append(group.Members, group.Admins...) {
+			pub_key, err := ParsePublicKey([]byte(pem_key))
+			if err != nil {
+				return nil, fmt.Errorf("could not parse key in access group %v... %w", group.Name, err)
+			}
+			key_string := PublicKeyToString(pub_key)
+			if acl.groups[key_string] == nil {
+				acl.groups[key_string] = map[string]bool{}
+			}
+			acl.groups[key_string][group.Name] = true
+		}
+		for _, pem_key := range group.Admins {
+			pub_key, _ := ParsePublicKey([]byte(pem_key))
+			acl.admins[PublicKeyToString(pub_key)] = true
+		}
+	}
+	return acl, nil
+}
+
+// Whether the key may use the relay at all.
+func (acl *AccessList) Allowed(pub_key string) bool {
+	if acl == nil {
+		return true
+	}
+	return len(acl.groups[pub_key]) > 0
+}
+
+// Whether the sender may publish to the recipient.
+func (acl *AccessList) CanPublish(sender string, recipient string) bool {
+	if acl == nil {
+		return true
+	}
+	if !acl.Allowed(recipient) {
+		return false
+	}
+	if acl.admins[sender] {
+		return true
+	}
+	for group := range acl.groups[sender] {
+		if acl.groups[recipient][group] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"crypto/rsa"
+	"net/http"
+	"testing"
+)
+
+func TestAccessList(t *testing.T) {
+	alice, bob, carol, admin, stranger := GenerateRandomKey(), GenerateRandomKey(), GenerateRandomKey(), GenerateRandomKey(), GenerateRandomKey()
+	pem := func(key *rsa.PrivateKey) string { return string(EncodePublicKey(key)) }
+	acl, err := NewAccessList([]AccessGroupConfig{
+		{Name: "friends", Members: []string{pem(alice), pem(bob)}},
+		{Name: "work", Members: []string{pem(carol)}, Admins: []string{pem(admin)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := func(key *rsa.PrivateKey) string { return PublicKeyToString(&key.PublicKey) }
+	if acl.Allowed(key(stranger)) {
+		t.Error("a key outside of every group should not be allowed")
+	}
+	if !acl.CanPublish(key(alice), key(bob)) {
+		t.Error("alice and bob share a group")
+	}
+	if acl.CanPublish(key(alice), key(carol)) {
+		t.Error("alice and carol don't share a group")
+	}
+	if !acl.CanPublish(key(admin), key(alice)) {
+		t.Error("admins can publish to every key on the relay")
+	}
+	if acl.CanPublish(key(admin), key(stranger)) {
+		t.Error("nobody can publish to a key outside of every group")
+	}
+}
+
+func TestOpenRelayAllowsEveryone(t *testing.T) {
+	acl, _ := NewAccessList(nil)
+	if !acl.Allowed("anyone") || !acl.CanPublish("anyone", "anyone else") {
+		t.Error("a relay without access groups should be open")
+	}
+}
+
+func TestAuthenticateRejectsNonMembers(t *testing.T) {
+	member := GenerateRandomKey()
+	acl, _ := NewAccessList([]AccessGroupConfig{
+		{Name: "friends", Members: []string{string(EncodePublicKey(member))}},
+	})
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), acl)
+	if code, reached := authenticate(cs, newSignedRequest([]byte("hello"))); code != http.StatusForbidden || reached {
+		t.Errorf("a key outside of the access groups should be rejected, got %v", code)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Request authentication between clients and the relay server.
+
+Every request carries the client's public key, a timestamp, a random
+nonce and a signature, along with the algorithm it was made with.
+The signature covers the algorithm, the HTTP method, the path, the
+target key header, the timestamp, the nonce and a hash of the body,
+so a captured set of headers can't be used for any other request.
+The server rejects timestamps outside of SIGNATURE_MAX_AGE and
+remembers every nonce it has seen for that long, so the same request
+can't be replayed either.
+
+Everything that only needs the headers is checked before the body is
+read: the timestamp, the key's access and whether the nonce was
+already used. A stale, replayed or unknown request is turned away
+without the relay reading or spooling its body.
+*/
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// How far a request timestamp may be from the server's clock.
+const SIGNATURE_MAX_AGE = time.Minute
+
+// Builds the text that gets signed for a request.
+// The body is included as a hex encoded SHA-256 hash.
+func requestSigningText(algorithm string, method string, path string, target_key string, timestamp string, nonce string, body_hash []byte) []byte {
+	return []byte(fmt.Sprintf(
+		"peppermint-request\n%s\n%s\n%s\n%s\n%s\n%s\n%s",
+		algorithm, method, path, target_key, timestamp, nonce, hex.EncodeToString(body_hash),
+	))
+}
+
+// Creates the auth headers for a request with the given method, path,
+// target key header (empty if the request has none) and body.
+func GenerateRequestAuthHeaders(identity Identity, method string, path string, target_key string, body []byte) *http.Header {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := RandomID()
+	algorithm := identity.SignatureAlgorithm()
+	body_hash := sha256.Sum256(body)
+	signature := CreateSignature(identity, requestSigningText(algorithm.String(), method, path, target_key, timestamp, nonce, body_hash[:]))
+	headers := http.Header{}
+	headers.Add(HEADER_SIGNATURE_VALUE, signature)
+	headers.Add(HEADER_SIGNATURE_ALGORITHM, algorithm.String())
+	headers.Add(HEADER_SIGNATURE_TIMESTAMP, timestamp)
+	headers.Add(HEADER_SIGNATURE_NONCE, nonce)
+	headers.Add(HEADER_PUBLIC_KEY, PublicKeyToString(identity.Public()))
+	return &headers
+}
+
+// Adds the auth headers to an outgoing request.
+// Set the target key header before calling this, since it is signed.
+func SignRequest(req *http.Request, identity Identity, body []byte) {
+	headers := GenerateRequestAuthHeaders(identity, req.Method, req.URL.Path, req.Header.Get(HEADER_TARGET_PUBLIC_KEY), body)
+	for name, values := range *headers {
+		req.Header[name] = values
+	}
+}
+
+// Returns the path part of a URL, which is what the server sees
+// and checks the signature against.
+func urlPath(raw_url string) string {
+	parsed, err := url.Parse(raw_url)
+	if err != nil {
+		return ""
+	}
+	return parsed.Path
+}
+
+// Remembers the nonces of recently authenticated requests.
+type ReplayCache struct {
+	mutex      sync.Mutex
+	seen       map[string]time.Time
+	max_age    time.Duration
+	last_prune time.Time
+}
+
+func NewReplayCache(max_age time.Duration) *ReplayCache {
+	return &ReplayCache{
+		seen:       map[string]time.Time{},
+		max_age:    max_age,
+		last_prune: time.Now(),
+	}
+}
+
+// Whether the nonce has already been recorded for the given key.
+// Lets a replay be turned away before its body is read, while Check
+// still records the nonce once the signature is verified.
+func (cache *ReplayCache) Seen(pub_key string, nonce string) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	expires_at, ok := cache.seen[pub_key+":"+nonce]
+	return ok && time.Now().Before(expires_at)
+}
+
+// Records the nonce for the given key.
+// Returns false if it was already recorded, meaning the request is a replay.
+func (cache *ReplayCache) Check(pub_key string, nonce string) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(cache.last_prune) > cache.max_age {
+		for entry, expires_at := range cache.seen {
+			if now.After(expires_at) {
+				delete(cache.seen, entry)
+			}
+		}
+		cache.last_prune = now
+	}
+	entry := pub_key + ":" + nonce
+	if _, ok := cache.seen[entry]; ok {
+		return false
+	}
+	// a timestamp can be up to max_age in the future, so keep the
+	// nonce around long enough to cover the whole window
+	cache.seen[entry] = now.Add(cache.max_age * 2)
+	return true
+}
+
+func (cs *ChatServer) authenticateRequest(endpoint func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		signature, err := hex.DecodeString(r.Header.Get(HEADER_SIGNATURE_VALUE))
+		if err != nil {
+			err_string := fmt.Sprintf("could not decode signature from header: %v", err)
+			http.Error(w, err_string, http.StatusBadRequest)
+			return
+		}
+		timestamp := r.Header.Get(HEADER_SIGNATURE_TIMESTAMP)
+		signed_at, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			http.Error(w, "could not parse signature timestamp from header", http.StatusBadRequest)
+			return
+		}
+		age := time.Since(time.Unix(signed_at, 0))
+		if age > SIGNATURE_MAX_AGE || age < -SIGNATURE_MAX_AGE {
+			http.Error(w, "signature timestamp is too far from the server's clock", http.StatusUnauthorized)
+			return
+		}
+		algorithm_name := r.Header.Get(HEADER_SIGNATURE_ALGORITHM)
+		algorithm := PBSignatureAlgorithm_SIGNATURE_RSA_PKCS1V15
+		if algorithm_name != "" {
+			value, ok := PBSignatureAlgorithm_value[algorithm_name]
+			if !ok {
+				http.Error(w, "unknown signature algorithm", http.StatusBadRequest)
+				return
+			}
+			algorithm = PBSignatureAlgorithm(value)
+		}
+		nonce := r.Header.Get(HEADER_SIGNATURE_NONCE)
+		if nonce == "" {
+			http.Error(w, "missing signature nonce", http.StatusBadRequest)
+			return
+		}
+		pub_key_str := r.Header.Get(HEADER_PUBLIC_KEY)
+		pub_key, err := PublicKeyFromString(pub_key_str)
+		if err != nil {
+			http.Error(w, "Could not parse public key from header...", http.StatusBadRequest)
+			return
+		}
+		if !cs.access.Allowed(pub_key_str) {
+			fmt.Printf("Rejected request from a key outside of the access groups, IP: %v\n", r.RemoteAddr)
+			http.Error(w, "your key is not a member of any group on this server", http.StatusForbidden)
+			return
+		}
+		if cs.replay_cache.Seen(pub_key_str, nonce) {
+			fmt.Printf("Rejected replayed request from IP: %v\n", r.RemoteAddr)
+			http.Error(w, "request has already been used", http.StatusUnauthorized)
+			return
+		}
+		body_hash, cleanup, err := cs.readRequestBody(w, r)
+		if err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		defer cleanup()
+		signed_text := requestSigningText(
+			algorithm_name, r.Method, r.URL.Path, r.Header.Get(HEADER_TARGET_PUBLIC_KEY), timestamp, nonce, body_hash,
+		)
+		verified := VerifyText(pub_key, algorithm, signed_text, signature)
+		if !verified {
+			fmt.Printf("Unable to verify request from IP: %v\n", r.RemoteAddr)
+			http.Error(w, "signature mismatch", http.StatusUnauthorized)
+			return
+		}
+		// checked again now that it's recorded, in case the same
+		// request came in twice while its body was being read
+		if !cs.replay_cache.Check(pub_key_str, nonce) {
+			fmt.Printf("Rejected replayed request from IP: %v\n", r.RemoteAddr)
+			http.Error(w, "request has already been used", http.StatusUnauthorized)
+			return
+		}
+		endpoint(w, r)
+	}
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Runs a request through authenticateRequest and reports
+// the status code and whether the endpoint was reached.
+func authenticate(cs *ChatServer, req *http.Request) (int, bool) {
+	reached := false
+	handler := cs.authenticateRequest(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	})
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	return recorder.Code, reached
+}
+
+func newSignedRequest(body []byte) *http.Request {
+	key := GenerateRandomKey()
+	req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewReader(body))
+	req.Header.Set(HEADER_TARGET_PUBLIC_KEY, "recipient")
+	SignRequest(req, NewKeyIdentity(key), body)
+	return req
+}
+
+func TestAuthenticateRequest(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	req := newSignedRequest([]byte("hello"))
+	if code, reached := authenticate(cs, req); code != http.StatusOK || !reached {
+		t.Errorf("a signed request should be let through, got %v", code)
+	}
+}
+
+func TestAuthenticateRejectsReplay(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	req := newSignedRequest([]byte("hello"))
+	authenticate(cs, req)
+	replay := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewReader([]byte("hello")))
+	replay.Header = req.Header
+	if code, reached := authenticate(cs, replay); code != http.StatusUnauthorized || reached {
+		t.Errorf("a replayed request should be rejected, got %v", code)
+	}
+}
+
+func TestAuthenticateRejectsTampering(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	req := newSignedRequest([]byte("hello"))
+	tampered := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewReader([]byte("goodbye")))
+	tampered.Header = req.Header
+	if code, reached := authenticate(cs, tampered); code != http.StatusUnauthorized || reached {
+		t.Errorf("a request with a different body should be rejected, got %v", code)
+	}
+	req = newSignedRequest([]byte("hello"))
+	req.Header.Set(HEADER_TARGET_PUBLIC_KEY, "someone else")
+	if code, reached := authenticate(cs, req); code != http.StatusUnauthorized || reached {
+		t.Errorf("a request with a different target should be rejected, got %v", code)
+	}
+	req = newSignedRequest([]byte("hello"))
+	req.URL.Path = "/subscribe"
+	if code, reached := authenticate(cs, req); code != http.StatusUnauthorized || reached {
+		t.Errorf("a request for a different path should be rejected, got %v", code)
+	}
+}
+
+func TestAuthenticateRejectsStaleTimestamp(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	req := newSignedRequest([]byte("hello"))
+	stale := time.Now().Add(-SIGNATURE_MAX_AGE * 2).Unix()
+	req.Header.Set(HEADER_SIGNATURE_TIMESTAMP, strconv.FormatInt(stale, 10))
+	if code, reached := authenticate(cs, req); code != http.StatusUnauthorized || reached {
+		t.Errorf("a stale request should be rejected, got %v", code)
+	}
+}
+
+func TestAuthenticateStopsOnBadHeader(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	req := newSignedRequest([]byte("hello"))
+	req.Header.Set(HEADER_SIGNATURE_VALUE, "not hex")
+	if code, reached := authenticate(cs, req); code != http.StatusBadRequest || reached {
+		t.Errorf("a bad signature header should stop the request, got %v", code)
+	}
+}
+
+// Records whether anything read the request body.
+type watchedBody struct {
+	read bool
+}
+
+func (body *watchedBody) Read(p []byte) (int, error) {
+	body.read = true
+	return 0, io.EOF
+}
+
+// A replayed or stale request is turned away on its headers alone,
+// without the relay reading its body.
+func TestAuthenticateChecksHeadersFirst(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	req := newSignedRequest(nil)
+	authenticate(cs, req)
+	body := &watchedBody{}
+	replay := httptest.NewRequest(http.MethodPost, "/publish", body)
+	replay.Header = req.Header
+	if code, _ := authenticate(cs, replay); code != http.StatusUnauthorized || body.read {
+		t.Errorf("a replay should be rejected before its body is read, got %v", code)
+	}
+	req = newSignedRequest(nil)
+	body = &watchedBody{}
+	stale := httptest.NewRequest(http.MethodPost, "/publish", body)
+	stale.Header = req.Header
+	stale.Header.Set(HEADER_SIGNATURE_TIMESTAMP, strconv.FormatInt(time.Now().Add(-SIGNATURE_MAX_AGE*2).Unix(), 10))
+	if code, _ := authenticate(cs, stale); code != http.StatusUnauthorized || body.read {
+		t.Errorf("a stale request should be rejected before its body is read, got %v", code)
+	}
+}
@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	RECONNECT_MIN_DELAY = time.Millisecond * 500
+	RECONNECT_MAX_DELAY = time.Second * 30
+)
+
+// Exponential backoff with jitter, used between reconnect attempts.
+// Each delay is picked at random from the upper half of the current
+// window so that clients dropped by the same server restart don't
+// all come back at once.
+type Backoff struct {
+	min     time.Duration
+	max     time.Duration
+	attempt int
+}
+
+func NewBackoff(min time.Duration, max time.Duration) *Backoff {
+	return &Backoff{min: min, max: max}
+}
+
+// Returns how long to wait before the next attempt.
+func (backoff *Backoff) Next() time.Duration {
+	window := backoff.min << backoff.attempt
+	if window > backoff.max || window <= 0 {
+		window = backoff.max
+	} else {
+		backoff.attempt++
+	}
+	half := window / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Starts the delays over after a successful connection.
+func (backoff *Backoff) Reset() {
+	backoff.attempt = 0
+}
@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffGrowsAndCaps(t *testing.T) {
+	backoff := NewBackoff(time.Second, time.Second*8)
+	windows := []time.Duration{1, 2, 4, 8, 8, 8}
+	for _, window := range windows {
+		delay := backoff.Next()
+		upper := window * time.Second
+		if delay < upper/2 || delay > upper {
+			t.Errorf("delay %v outside of [%v, %v]", delay, upper/2, upper)
+		}
+	}
+	backoff.Reset()
+	if delay := backoff.Next(); delay > time.Second {
+		t.Errorf("delay after reset should be back in the first window, got %v", delay)
+	}
+}
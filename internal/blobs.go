@@ -0,0 +1,340 @@
+/*
+Blob storage for the relay server.
+
+Files are uploaded to the relay as blobs, already encrypted by the
+sender. A blob is created with its full size and then filled in by
+PUTs at increasing offsets, so an upload that is cut off can carry on
+from wherever the relay got to. Once complete, a blob can be read from
+any offset by anyone who shares a group with the uploader.
+
+Each blob is two files in the blob directory: the contents under the
+blob's ID, and a PBBlobInfo next to it with .info on the end. Blobs
+are deleted once they are older than the mailbox TTL.
+*/
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// full size of a blob being created
+	HEADER_BLOB_SIZE = "BLOB_SIZE"
+	// where a chunk goes, or where a download starts
+	HEADER_BLOB_OFFSET    = "BLOB_OFFSET"
+	DEFAULT_MAX_BLOB_SIZE = 1024 * 1024 * 100
+	// the most the relay takes in one PUT
+	MAX_BLOB_CHUNK = 1024 * 1024 * 4
+	// chunks being read, before they are appended to their blob
+	BLOB_SPOOL_PREFIX = "upload-"
+	// spooled chunks older than this were left behind by a crash
+	BLOB_SPOOL_MAX_AGE = time.Hour
+)
+
+var (
+	errBlobNotFound   = errors.New("no such file on the server")
+	errBlobTooLarge   = errors.New("file is larger than the server allows")
+	errBlobNotOwner   = errors.New("file was uploaded by someone else")
+	errBlobIncomplete = errors.New("file hasn't finished uploading")
+)
+
+// Returned when a chunk doesn't start where the blob's contents end.
+type BlobOffsetError struct {
+	Offset int64
+}
+
+func (err *BlobOffsetError) Error() string {
+	return fmt.Sprintf("server has the file up to offset %v", err.Offset)
+}
+
+var blob_id_pattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Whether the ID could be a blob's, so it's safe to use as a file name.
+func validBlobID(id string) bool {
+	return blob_id_pattern.MatchString(id)
+}
+
+type BlobStore struct {
+	// one upload is appended at a time
+	mutex    sync.Mutex
+	dir      string
+	max_size int64
+	ttl      time.Duration
+}
+
+// Opens the blob directory, creating it if needed.
+func OpenBlobStore(dir string, max_size int64, ttl time.Duration) (*BlobStore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("could not create blob directory... %w", err)
+	}
+	if max_size <= 0 {
+		max_size = DEFAULT_MAX_BLOB_SIZE
+	}
+	if ttl <= 0 {
+		ttl = DEFAULT_MAILBOX_TTL
+	}
+	return &BlobStore{dir: dir, max_size: max_size, ttl: ttl}, nil
+}
+
+func (store *BlobStore) path(id string) string {
+	return filepath.Join(store.dir, id)
+}
+
+func (store *BlobStore) readInfo(id string) (*PBBlobInfo, error) {
+	if !validBlobID(id) {
+		return nil, errBlobNotFound
+	}
+	data, err := os.ReadFile(store.path(id) + ".info")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read blob info... %w", err)
+	}
+	info := &PBBlobInfo{}
+	err = proto.Unmarshal(data, info)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse blob info... %w", err)
+	}
+	return info, nil
+}
+
+// How much of the blob has been uploaded.
+func (store *BlobStore) uploaded(id string) (int64, error) {
+	stat, err := os.Stat(store.path(id))
+	if err != nil {
+		return 0, fmt.Errorf("could not read blob... %w", err)
+	}
+	return stat.Size(), nil
+}
+
+// Makes an empty blob of the given size for the owner to fill in,
+// and returns its ID.
+func (store *BlobStore) Create(owner string, size int64) (string, error) {
+	if size < 0 || size > store.max_size {
+		return "", errBlobTooLarge
+	}
+	id := RandomID()
+	data, err := proto.Marshal(&PBBlobInfo{Owner: owner, Size: size, CreatedAt: time.Now().Unix()})
+	if err != nil {
+		return "", fmt.Errorf("could not serialize blob info... %w", err)
+	}
+	err = os.WriteFile(store.path(id), nil, 0600)
+	if err != nil {
+		return "", fmt.Errorf("could not create blob... %w", err)
+	}
+	err = os.WriteFile(store.path(id)+".info", data, 0600)
+	if err != nil {
+		os.Remove(store.path(id))
+		return "", fmt.Errorf("could not create blob... %w", err)
+	}
+	return id, nil
+}
+
+// Adds the chunk to the end of the owner's blob. The offset has to be
+// where the contents end now, otherwise a *BlobOffsetError says where
+// that is. Returns the new end of the contents.
+func (store *BlobStore) Append(id string, owner string, offset int64, chunk io.Reader) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	info, err := store.readInfo(id)
+	if err != nil {
+		return 0, err
+	}
+	if info.GetOwner() != owner {
+		return 0, errBlobNotOwner
+	}
+	uploaded, err := store.uploaded(id)
+	if err != nil {
+		return 0, err
+	}
+	if offset != uploaded {
+		return 0, &BlobOffsetError{Offset: uploaded}
+	}
+	file, err := os.OpenFile(store.path(id), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return 0, fmt.Errorf("could not open blob... %w", err)
+	}
+	defer file.Close()
+	// one byte past the size is enough to know the chunk doesn't fit
+	written, err := io.Copy(file, io.LimitReader(chunk, info.GetSize()-uploaded+1))
+	if err == nil && uploaded+written > info.GetSize() {
+		err = errBlobTooLarge
+	}
+	if err != nil {
+		// drop whatever part of the chunk made it, so the offset stays whole
+		file.Truncate(uploaded)
+		if errors.Is(err, errBlobTooLarge) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("could not write blob... %w", err)
+	}
+	return uploaded + written, nil
+}
+
+// Opens a complete blob for reading.
+func (store *BlobStore) Open(id string) (*PBBlobInfo, *os.File, error) {
+	info, err := store.readInfo(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	uploaded, err := store.uploaded(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uploaded < info.GetSize() {
+		return nil, nil, errBlobIncomplete
+	}
+	file, err := os.Open(store.path(id))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open blob... %w", err)
+	}
+	return info, file, nil
+}
+
+// Deletes the blobs that are older than the TTL,
+// and any spooled chunks that were never appended.
+func (store *BlobStore) Compact() error {
+	entries, err := os.ReadDir(store.dir)
+	if err != nil {
+		return fmt.Errorf("could not list blobs... %w", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), BLOB_SPOOL_PREFIX) {
+			stat, err := entry.Info()
+			if err == nil && time.Since(stat.ModTime()) > BLOB_SPOOL_MAX_AGE {
+				os.Remove(filepath.Join(store.dir, entry.Name()))
+			}
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".info")
+		if id == entry.Name() {
+			continue
+		}
+		info, err := store.readInfo(id)
+		if err != nil {
+			continue
+		}
+		if time.Since(time.Unix(info.GetCreatedAt(), 0)) > store.ttl {
+			os.Remove(store.path(id))
+			os.Remove(store.path(id) + ".info")
+		}
+	}
+	return nil
+}
+
+// A POST creates a blob for the sender, with the size in the blob
+// size header, and responds with its ID.
+func (cs *ChatServer) createBlobHandler(w http.ResponseWriter, r *http.Request) {
+	if cs.blobs == nil {
+		http.Error(w, "this server does not store files", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	size, err := strconv.ParseInt(r.Header.Get(HEADER_BLOB_SIZE), 10, 64)
+	if err != nil {
+		http.Error(w, "could not parse blob size from header", http.StatusBadRequest)
+		return
+	}
+	id, err := cs.blobs.Create(r.Header.Get(HEADER_PUBLIC_KEY), size)
+	if err != nil {
+		writeBlobError(w, err)
+		return
+	}
+	w.Write([]byte(id))
+}
+
+// A PUT to /blobs/<id> adds a chunk at the offset in the blob offset
+// header. A GET reads the blob from that offset to the end.
+func (cs *ChatServer) blobHandler(w http.ResponseWriter, r *http.Request) {
+	if cs.blobs == nil {
+		http.Error(w, "this server does not store files", http.StatusNotFound)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/blobs/")
+	sender := r.Header.Get(HEADER_PUBLIC_KEY)
+	var offset int64
+	if offset_header := r.Header.Get(HEADER_BLOB_OFFSET); offset_header != "" {
+		var err error
+		offset, err = strconv.ParseInt(offset_header, 10, 64)
+		if err != nil || offset < 0 {
+			http.Error(w, "could not parse blob offset from header", http.StatusBadRequest)
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodPut:
+		// the body was spooled to disk and size checked when it was authenticated
+		uploaded, err := cs.blobs.Append(id, sender, offset, r.Body)
+		if err != nil {
+			writeBlobError(w, err)
+			return
+		}
+		w.Header().Set(HEADER_BLOB_OFFSET, strconv.FormatInt(uploaded, 10))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		info, file, err := cs.blobs.Open(id)
+		if err != nil {
+			writeBlobError(w, err)
+			return
+		}
+		defer file.Close()
+		if !cs.access.CanPublish(sender, info.GetOwner()) {
+			http.Error(w, "the file's owner does not share a group with you", http.StatusForbidden)
+			return
+		}
+		if offset > info.GetSize() {
+			http.Error(w, "offset is past the end of the file", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		_, err = file.Seek(offset, io.SeekStart)
+		if err != nil {
+			http.Error(w, "could not read blob", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set(HEADER_BLOB_SIZE, strconv.FormatInt(info.GetSize(), 10))
+		w.Header().Set("Content-Length", strconv.FormatInt(info.GetSize()-offset, 10))
+		io.Copy(w, file)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Turns a blob store error into the matching status.
+// A wrong offset is a conflict, with the right one in the header.
+func writeBlobError(w http.ResponseWriter, err error) {
+	var offset_err *BlobOffsetError
+	switch {
+	case errors.As(err, &offset_err):
+		w.Header().Set(HEADER_BLOB_OFFSET, strconv.FormatInt(offset_err.Offset, 10))
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, errBlobNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errBlobTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, errBlobNotOwner):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, errBlobIncomplete):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -6,11 +6,12 @@ load and parse the PPMT configuration file.
 package internal
 
 import (
-	"crypto/rsa"
 	"embed"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -40,18 +41,49 @@ func createPPMTConfig() (string, string) {
 	return ppmt_path, ppmt_config
 }
 
-// Write the config file and generate a new, random RSA keyfile
-func InitPPMT() {
+// Write the config file and generate a new, random keyfile
+// of the given type (rsa or ed25519), named the way ssh-keygen would.
+// Run in a terminal, it asks for a passphrase to encrypt the keyfile with.
+func InitPPMT(key_type string) {
+	key, err := GenerateKey(key_type)
+	CheckErrFatal(err)
+	var passphrase []byte
+	if HasTerminal() {
+		passphrase, err = PromptNewPassphrase()
+		CheckErrFatal(err)
+	}
 	ppmt_path, _ := createPPMTConfig()
-	WriteKeyToDisk(GenerateRandomKey(), filepath.Join(ppmt_path, "id_rsa"))
+	key_file := filepath.Join(ppmt_path, "id_"+key_type)
+	WriteKeyToDisk(key, key_file, passphrase)
 	fmt.Println("Created peppermint config and private key files in dir:", ppmt_path)
+	fmt.Println("Set private_key_file in the config to:", key_file)
 }
 
 type MessangerConfig struct {
-	Users      []RecipientConfig
-	PrivateKey *rsa.PrivateKey
-	URL        string
-	Port       string
+	Users    []RecipientConfig
+	Identity Identity
+	URL      string
+	Port     string
+	// SHA-256 fingerprint of the server's certificate, for self-signed servers
+	TLSFingerprint string `mapstructure:"tls_fingerprint"`
+	// Signed into every message. Defaults to the group's name in the config.
+	GroupID string `mapstructure:"group_id"`
+	// Tell friends when you've seen their messages, not just received them
+	ReadReceipts bool `mapstructure:"read_receipts"`
+	// The group's name in the config
+	Name string `mapstructure:"-"`
+	// Where the prekeys are kept, next to the private key
+	PrekeyFile string `mapstructure:"-"`
+	// The keys checked with 'peppermint verify'
+	VerifiedFile string `mapstructure:"-"`
+	// Where people who join with an invite, and friends' new keys, are written
+	ConfigFile string `mapstructure:"-"`
+	// Where files offered to us are recorded, see files.go
+	FilesDir string `mapstructure:"-"`
+	// Which of our devices this is, so the relay keeps messages for the others, see device.go
+	DeviceID string `mapstructure:"-"`
+	// Every other group in the config, so the reader can place their messages
+	OtherGroups []MessangerConfig `mapstructure:"-"`
 }
 
 type RecipientConfig struct {
@@ -59,6 +91,24 @@ type RecipientConfig struct {
 	Name string
 }
 
+// Settings for 'peppermint host'.
+type HostConfig struct {
+	Port               string
+	MailboxTTL         time.Duration
+	MailboxQuota       int
+	MaxMailboxes       int
+	StorageFile        string
+	CompactionInterval time.Duration
+	AccessGroups       []AccessGroupConfig
+	TLSCertFile        string
+	TLSKeyFile         string
+	TLSSelfSigned      bool
+	BlobDir            string
+	MaxBlobSize        int64
+	MaxEnvelopeSize    int64
+	MaxBatchSize       int64
+}
+
 // Parse the config with Viper and handle errors
 func ParseConfig() {
 	if err := viper.ReadInConfig(); err != nil {
@@ -67,19 +117,132 @@ func ParseConfig() {
 	}
 }
 
+// Reads the relay server settings from the top level of the config.
+// Mailbox settings fall back to the defaults when unset,
+// and mailboxes are kept in memory when no storage_file is given.
+// A self-signed certificate goes in ~/.peppermint unless paths are given,
+// and so do uploaded files unless blob_dir is given.
+func ParseHostConfig() *HostConfig {
+	ParseConfig()
+	host_config := HostConfig{
+		Port:               viper.GetString("port"),
+		MailboxTTL:         viper.GetDuration("mailbox_ttl"),
+		MailboxQuota:       viper.GetInt("mailbox_quota"),
+		MaxMailboxes:       viper.GetInt("max_mailboxes"),
+		StorageFile:        viper.GetString("storage_file"),
+		CompactionInterval: viper.GetDuration("compaction_interval"),
+		BlobDir:            viper.GetString("blob_dir"),
+		MaxBlobSize:        viper.GetInt64("max_blob_size"),
+		MaxEnvelopeSize:    viper.GetInt64("max_envelope_size"),
+		MaxBatchSize:       viper.GetInt64("max_batch_size"),
+	}
+	err := viper.UnmarshalKey("access_groups", &host_config.AccessGroups)
+	CheckErrFatal(err)
+	host_config.TLSCertFile = viper.GetString("tls_cert_file")
+	host_config.TLSKeyFile = viper.GetString("tls_key_file")
+	host_config.TLSSelfSigned = viper.GetBool("tls_self_signed")
+	if host_config.TLSSelfSigned && host_config.TLSCertFile == "" {
+		home, err := os.UserHomeDir()
+		CheckErrFatal(err)
+		host_config.TLSCertFile = filepath.Join(home, ".peppermint", "relay_cert.pem")
+		host_config.TLSKeyFile = filepath.Join(home, ".peppermint", "relay_key.pem")
+	}
+	if host_config.BlobDir == "" {
+		home, err := os.UserHomeDir()
+		CheckErrFatal(err)
+		host_config.BlobDir = filepath.Join(home, ".peppermint", "blobs")
+	}
+	return &host_config
+}
+
 func ParseConfigWithViper(group string) *MessangerConfig {
 	var group_config MessangerConfig
 	ParseConfig()
+	err := viper.UnmarshalKey(group, &group_config)
+	CheckErrFatal(err)
+	group_config.Identity, group_config.PrekeyFile = parseIdentity()
+	group_config.VerifiedFile = DefaultVerifiedFile()
+	group_config.ConfigFile = viper.ConfigFileUsed()
+	group_config.FilesDir = DefaultFilesDir()
+	group_config.DeviceID = loadDeviceIDOrWarn(DefaultDeviceFile())
+	group_config.Name = strings.ToLower(group)
+	if group_config.GroupID == "" {
+		group_config.GroupID = group_config.Name
+	}
+	group_config.OtherGroups = parseOtherGroups(group_config.Name)
+	return &group_config
+}
+
+// Loads the identity from ssh-agent if ssh_agent_key is set,
+// otherwise from private_key_file. Also returns where its prekeys go.
+// An agent identity can only read messages through its prekeys, so it
+// is refused if its prekey store can't be used.
+func parseIdentity() (Identity, string) {
+	if fingerprint := viper.GetString("ssh_agent_key"); fingerprint != "" {
+		identity, err := NewAgentIdentity(fingerprint)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		home, err := os.UserHomeDir()
+		CheckErrFatal(err)
+		prekey_file, err := agentPrekeyFile(identity, filepath.Join(home, ".peppermint"), fingerprint)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return identity, prekey_file
+	}
 	keyFile := viper.GetString("private_key_file")
 	if keyFile == "" {
 		fmt.Print("Nil value for keyfile!\n")
 		os.Exit(1)
 	}
-	err := viper.UnmarshalKey(group, &group_config)
-	CheckErrFatal(err)
-	key := ReadExistingKey(keyFile)
-	group_config.PrivateKey = key
-	return &group_config
+	return NewKeyIdentity(ReadExistingKey(keyFile)), keyFile + ".prekeys"
+}
+
+// Returns the prekey file for an agent identity in dir, making sure it
+// can be read and written and has a current prekey. ssh-agent keys
+// can't decrypt, so without it none of our messages could be read.
+func agentPrekeyFile(identity Identity, dir string, fingerprint string) (string, error) {
+	prekey_file := filepath.Join(dir, AgentPrekeyFileName(fingerprint))
+	err := os.MkdirAll(dir, 0700)
+	if err == nil {
+		var store *PrekeyStore
+		store, err = OpenPrekeyStore(prekey_file)
+		if err == nil {
+			_, err = store.Refresh(identity)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("ssh-agent keys can't decrypt, so messages are read with the prekeys in %v, which can't be used... %w", prekey_file, err)
+	}
+	return prekey_file, nil
+}
+
+// Reads every group in the config except the named one.
+// Groups are the top level tables with a list of users.
+func parseOtherGroups(group string) []MessangerConfig {
+	var groups []MessangerConfig
+	for name, value := range viper.AllSettings() {
+		settings, ok := value.(map[string]interface{})
+		if !ok || name == group {
+			continue
+		}
+		if _, ok := settings["users"]; !ok {
+			continue
+		}
+		var other MessangerConfig
+		if err := viper.UnmarshalKey(name, &other); err != nil {
+			continue
+		}
+		other.Name = name
+		if other.GroupID == "" {
+			other.GroupID = name
+		}
+		groups = append(groups, other)
+	}
+	return groups
 }
 
 // Checks to see if --verbose is set by the user
@@ -19,7 +19,8 @@ import (
 	"io"
 	"os"
 
-	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
 )
 
 const LABEL = "myCoolMessagingApp"
@@ -70,6 +71,40 @@ func AESDecrypt(ciphertext []byte, key []byte) ([]byte, error) {
 	return decrypted_bytes, nil
 }
 
+// Generates a random X25519 key pair.
+func GenerateX25519Key() (private_key []byte, public_key []byte) {
+	private_key = make([]byte, curve25519.ScalarSize)
+	_, err := rand.Read(private_key)
+	CheckErrFatal(err)
+	public_key, err = curve25519.X25519(private_key, curve25519.Basepoint)
+	CheckErrFatal(err)
+	return private_key, public_key
+}
+
+// Agrees a secret between an X25519 private key and a peer's public
+// key, and derives a 32 byte AES key from it with HKDF-SHA256.
+// The info should bind the key to what it is used for.
+func DeriveX25519Key(private_key []byte, peer_public_key []byte, info []byte) ([]byte, error) {
+	shared, err := curve25519.X25519(private_key, peer_public_key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to agree on a shared secret...%w", err)
+	}
+	key := make([]byte, 32)
+	_, err = io.ReadFull(hkdf.New(sha256.New, shared, nil, info), key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to derive key...%w", err)
+	}
+	return key, nil
+}
+
+// Returns a random, hex encoded 16 byte identifier
+func RandomID() string {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	CheckErrFatal(err)
+	return hex.EncodeToString(id)
+}
+
 // Convert a byte slice to a hex string
 func BytesToString(sig []byte) string {
 	return hex.EncodeToString(sig)
@@ -133,42 +168,41 @@ func RSAVerify(pub *rsa.PublicKey, message []byte, sig []byte) bool {
 	return true
 }
 
-// Reads an existing .pem or rsa keyfile and returns a
-// reference to it.
-func ReadExistingKey(keyFile string) *rsa.PrivateKey {
+// Reads an existing .pem, rsa or OpenSSH keyfile and returns a
+// reference to it. RSA and Ed25519 keys are supported.
+// Asks for the passphrase on the terminal if the keyfile is encrypted.
+func ReadExistingKey(keyFile string) crypto.Signer {
 	keyfile, err := os.ReadFile(keyFile)
 	if err != nil {
 		fmt.Printf("Could not read file %v :%v\n", keyFile, err)
 		os.Exit(1)
 	}
-	key, err := ssh.ParseRawPrivateKey(keyfile)
-	CheckErrFatal(err)
-	return key.(*rsa.PrivateKey)
+	signer, err := ParsePrivateKey(keyfile, func() ([]byte, error) {
+		return PromptPassphrase(fmt.Sprintf("Enter passphrase for %v: ", keyFile))
+	})
+	if err != nil {
+		fmt.Printf("Could not read key %v :%v\n", keyFile, err)
+		os.Exit(1)
+	}
+	return signer
 }
 
-// Returns a public RSA key from a []byte representation
+// Returns a public key from a []byte representation
 // of a PEM encoded key.
-func ParsePublicKey(keyString []byte) (*rsa.PublicKey, error) {
+func ParsePublicKey(keyString []byte) (crypto.PublicKey, error) {
 	pKeyBlock, _ := pem.Decode(keyString)
 	if pKeyBlock == nil {
 		return nil, fmt.Errorf("error in pem.Decode, keyblock is nil")
 	}
-	pubKeyInterface, err := x509.ParsePKIXPublicKey(pKeyBlock.Bytes)
-	if err != nil {
-		return nil, fmt.Errorf("error in x509.ParsePKIXPublicKey... %w", err)
-	}
-	pubKey := pubKeyInterface.(*rsa.PublicKey)
-	return pubKey, nil
+	return BytesToPublicKey(pKeyBlock.Bytes)
 }
 
-func WriteKeyToDisk(key *rsa.PrivateKey, fileName string) {
-	pemData := pem.EncodeToMemory(
-		&pem.Block{
-			Type:  "RSA PRIVATE KEY",
-			Bytes: x509.MarshalPKCS1PrivateKey(key),
-		},
-	)
-	err := os.WriteFile(fileName, pemData, 0600)
+// Writes the private key, and the public key next to it with .pub
+// The private key is encrypted unless the passphrase is empty.
+func WriteKeyToDisk(key crypto.Signer, fileName string, passphrase []byte) {
+	pemData, err := MarshalEncryptedPrivateKey(key, passphrase)
+	CheckErrFatal(err)
+	err = os.WriteFile(fileName, pemData, 0600)
 	CheckErrFatal(err)
 	pub_key := EncodePublicKey(key)
 	err = os.WriteFile(fileName+".pub", pub_key, 0600)
@@ -177,29 +211,37 @@ func WriteKeyToDisk(key *rsa.PrivateKey, fileName string) {
 
 // Produces the public key bytearray from the given private key
 // Produces a PEM encoded representation of the Public Key
-func EncodePublicKey(key *rsa.PrivateKey) []byte {
-	pubKey := key.PublicKey
-	pubKeyBytes, err := x509.MarshalPKIXPublicKey(&pubKey)
+func EncodePublicKey(key crypto.Signer) []byte {
+	return PublicKeyToPEM(key.Public())
+}
+
+// PEM encodes a public key. This is the inverse of ParsePublicKey.
+func PublicKeyToPEM(pub_key crypto.PublicKey) []byte {
+	pubKeyBytes, err := x509.MarshalPKIXPublicKey(pub_key)
 	if err != nil {
 		panic(err)
 	}
+	block_type := "PUBLIC KEY"
+	if _, ok := pub_key.(*rsa.PublicKey); ok {
+		block_type = "RSA PUBLIC KEY"
+	}
 	pemData := pem.EncodeToMemory(
 		&pem.Block{
-			Type:  "RSA PUBLIC KEY",
+			Type:  block_type,
 			Bytes: pubKeyBytes,
 		},
 	)
 	return pemData
 }
 
-func WritePublicKey(key *rsa.PrivateKey, fileName string) {
+func WritePublicKey(key crypto.Signer, fileName string) {
 	bytes := EncodePublicKey(key)
 	err := os.WriteFile(fileName, bytes, 0600)
 	CheckErrFatal(err)
 }
 
 // Prints the public key byte array from a given private key
-func DisplayPublicKey(key *rsa.PrivateKey) {
+func DisplayPublicKey(key crypto.Signer) {
 	pem_key := EncodePublicKey(key)
 	fmt.Println(string(pem_key))
 }
@@ -211,27 +253,30 @@ func GenerateRandomKey() *rsa.PrivateKey {
 	return k
 }
 
-// Converts a public RSA key into []byte serializing with x509.MarshalPKIXPublicKey().
+// Converts a public key into []byte serializing with x509.MarshalPKIXPublicKey().
 // This is the inverse of BytesToPublicKey.
-func PublicKeyToBytes(key *rsa.PublicKey) []byte {
+func PublicKeyToBytes(key crypto.PublicKey) []byte {
 	pubKeyBytes, err := x509.MarshalPKIXPublicKey(key)
 	CheckErrFatal(err)
 	return pubKeyBytes
 }
 
-// Converts a public RSA serialized as []byte back into *rsa.PublicKey.
+// Converts a public key serialized as []byte back into a public key.
 // This is the inverse of PublicKeyToBytes.
 // Uses x509.ParsePKIXPublicKey()
-func BytesToPublicKey(key_bytes []byte) (*rsa.PublicKey, error) {
+func BytesToPublicKey(key_bytes []byte) (crypto.PublicKey, error) {
 	pub_key, err := x509.ParsePKIXPublicKey(key_bytes)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse Bytes into Public Key...%w", err)
 	}
-	return pub_key.(*rsa.PublicKey), nil
+	if _, err := KeyType(pub_key); err != nil {
+		return nil, err
+	}
+	return pub_key, nil
 }
 
 // Returns the x509 format encoded with hex as a string
-func PublicKeyToString(key *rsa.PublicKey) string {
+func PublicKeyToString(key crypto.PublicKey) string {
 	pubKeyBytes, err := x509.MarshalPKIXPublicKey(key)
 	CheckErrFatal(err)
 	return hex.EncodeToString(pubKeyBytes)
@@ -239,7 +284,7 @@ func PublicKeyToString(key *rsa.PublicKey) string {
 
 // Parses a string that is assumed to be a hex encoded []byte produced
 // by x509 encoding a public key.
-func PublicKeyFromString(key_string string) (*rsa.PublicKey, error) {
+func PublicKeyFromString(key_string string) (crypto.PublicKey, error) {
 	pub_key_bytes, err := hex.DecodeString(key_string)
 	if err != nil {
 		return nil, fmt.Errorf("could not hex decode the given string...%w", err)
@@ -247,14 +292,12 @@ func PublicKeyFromString(key_string string) (*rsa.PublicKey, error) {
 	return BytesToPublicKey(pub_key_bytes)
 }
 
-// Creates a random string and then signs it.
-// Returns two strings which are []byte's that were Hex encoded
-func CreateSignature(key *rsa.PrivateKey) (string, string) {
-	token := make([]byte, 64)
-	_, _ = rand.Reader.Read(token)
-	signed, err := RSASign(key, token)
+// Signs the given text with the identity.
+// Returns the signature as a hex encoded string.
+func CreateSignature(identity Identity, text []byte) string {
+	signed, err := identity.Sign(text)
 	if err != nil {
 		panic(err)
 	}
-	return hex.EncodeToString(signed), hex.EncodeToString(token)
+	return hex.EncodeToString(signed)
 }
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Where the ID the relay knows this device by is kept, in ~/.peppermint.
+const DEVICE_ID_FILE = "device_id"
+
+// The default place the device ID is kept.
+func DefaultDeviceFile() string {
+	home, err := os.UserHomeDir()
+	CheckErrFatal(err)
+	return filepath.Join(home, ".peppermint", DEVICE_ID_FILE)
+}
+
+// Reads this device's ID, making one up the first time. The relay
+// keeps envelopes until every device reading a key has acked them,
+// so each device needs its own ID, and every reader on the device
+// shares it.
+func LoadDeviceID(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) != "" {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("could not read device ID... %w", err)
+	}
+	device_id := RandomID()
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err == nil {
+		err = os.WriteFile(path, []byte(device_id+"\n"), 0600)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not save device ID... %w", err)
+	}
+	return device_id, nil
+}
+
+// Loads the device ID, carrying on without one if it can't be read
+// or saved. The relay then treats us like a client that predates
+// device IDs.
+func loadDeviceIDOrWarn(path string) string {
+	device_id, err := LoadDeviceID(path)
+	if err != nil {
+		fmt.Println(err)
+	}
+	return device_id
+}
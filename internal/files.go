@@ -0,0 +1,573 @@
+/*
+Sending files.
+
+A file is encrypted once with a random AES key, a chunk at a time, and
+uploaded to a blob on the relay (see blobs.go). Then a payload offering
+the file goes to the group like any other message. It carries the
+blob's ID, the file's name, size and SHA-256 hash, and the key, and is
+encrypted for each recipient like any other payload, so only they can
+read the key.
+
+An upload that is cut off is resumed from wherever the relay got to.
+Uploads in progress are recorded in ~/.peppermint/files/uploads, so
+sending the same file again picks up where the last try stopped.
+
+Readers record the files offered to them in ~/.peppermint/files, and
+'peppermint fetch <id>' downloads one, decrypts it and checks its hash.
+A download that is cut off carries on from the last whole chunk.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	FILE_CHUNK_SIZE = 1024 * 1024
+	// AES-GCM adds a nonce and a tag to every chunk
+	FILE_CHUNK_OVERHEAD = 12 + 16
+	// how many times in a row a chunk can fail before giving up
+	FILE_TRANSFER_ATTEMPTS = 5
+	// for creating a blob or uploading one chunk
+	BLOB_REQUEST_TIMEOUT = time.Second * 30
+)
+
+var errFileDamaged = errors.New("file was damaged or tampered with")
+
+// Implemented by transports that can upload files for a file offer.
+type FileTransport interface {
+	UploadFile(path string, state_dir string, progress func(done int64, total int64)) (*PBFileOffer, error)
+}
+
+// Where offered files and uploads in progress are recorded.
+func DefaultFilesDir() string {
+	home, err := os.UserHomeDir()
+	CheckErrFatal(err)
+	return filepath.Join(home, ".peppermint", "files")
+}
+
+// The size of a file once each of its chunks is encrypted.
+func encryptedFileSize(size int64, chunk_size int64) int64 {
+	chunks := (size + chunk_size - 1) / chunk_size
+	return size + chunks*FILE_CHUNK_OVERHEAD
+}
+
+// Sizes in the units people expect.
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024*1024:
+		return fmt.Sprintf("%.1f GB", float64(size)/(1024*1024*1024))
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+// Prints how far a transfer has got, on one line.
+func printProgress(verb string, name string) func(int64, int64) {
+	return func(done int64, total int64) {
+		fmt.Fprintf(output, "\r%v %v: %v%%", verb, name, done*100/total)
+		if done == total {
+			fmt.Fprintln(output)
+		}
+	}
+}
+
+// Makes a signed request to the relay's blob endpoints.
+// Responses other than a 200 are turned into errors, with a conflict
+// on a PUT becoming a *BlobOffsetError.
+func (webt *WEBTransport) blobRequest(ctx context.Context, method string, path string, headers map[string]string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, webt.host_url+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("problem constructing blob request... %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	SignRequest(req, webt.identity, body)
+	resp, err := webt.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("problem performing blob request... %w", err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	resp_body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	message := strings.TrimSpace(string(resp_body))
+	switch resp.StatusCode {
+	case http.StatusConflict:
+		offset, err := strconv.ParseInt(resp.Header.Get(HEADER_BLOB_OFFSET), 10, 64)
+		if err == nil {
+			return nil, &BlobOffsetError{Offset: offset}
+		}
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w... %s", errBlobNotFound, message)
+	case http.StatusRequestEntityTooLarge:
+		return nil, fmt.Errorf("%w... %s", errBlobTooLarge, message)
+	}
+	return nil, fmt.Errorf("blob request failed... %s", message)
+}
+
+// Creates a blob of the given size on the relay and returns its ID.
+func (webt *WEBTransport) createBlob(size int64) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), BLOB_REQUEST_TIMEOUT)
+	defer cancel()
+	headers := map[string]string{HEADER_BLOB_SIZE: strconv.FormatInt(size, 10)}
+	resp, err := webt.blobRequest(ctx, http.MethodPost, "/blobs", headers, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	id, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil || !validBlobID(string(id)) {
+		return "", errors.New("server did not return a usable blob ID")
+	}
+	return string(id), nil
+}
+
+// Uploads one encrypted chunk to the blob at the given offset.
+func (webt *WEBTransport) putBlobChunk(id string, offset int64, chunk []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), BLOB_REQUEST_TIMEOUT)
+	defer cancel()
+	headers := map[string]string{HEADER_BLOB_OFFSET: strconv.FormatInt(offset, 10)}
+	resp, err := webt.blobRequest(ctx, http.MethodPut, "/blobs/"+id, headers, chunk)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Reads the blob from the given offset to the end.
+func (webt *WEBTransport) getBlob(id string, offset int64) (io.ReadCloser, error) {
+	headers := map[string]string{HEADER_BLOB_OFFSET: strconv.FormatInt(offset, 10)}
+	resp, err := webt.blobRequest(context.Background(), http.MethodGet, "/blobs/"+id, headers, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Where an upload of the file to the relay is recorded.
+func uploadStatePath(state_dir string, url string, path string) string {
+	abs_path, err := filepath.Abs(path)
+	if err != nil {
+		abs_path = path
+	}
+	digest := sha256.Sum256([]byte(url + "\n" + abs_path))
+	return filepath.Join(state_dir, hex.EncodeToString(digest[:16]))
+}
+
+// Reads a recorded upload. A missing or unreadable record means
+// there's nothing to resume.
+func loadUploadState(path string) *PBUploadState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	state := &PBUploadState{}
+	if proto.Unmarshal(data, state) != nil {
+		return nil
+	}
+	return state
+}
+
+func saveUploadState(path string, state *PBUploadState) error {
+	data, err := proto.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("could not serialize upload state... %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return fmt.Errorf("could not record upload... %w", err)
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Hashes the whole file with SHA-256.
+func hashFile(file *os.File) ([]byte, error) {
+	_, err := file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.New()
+	_, err = io.Copy(digest, file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file... %w", err)
+	}
+	return digest.Sum(nil), nil
+}
+
+// Encrypts the file and uploads it to a blob on the relay, carrying on
+// with an earlier upload of the same file if there is one. Returns the
+// offer to send to the group. Without a state directory uploads aren't
+// recorded, so they can only be resumed while this runs.
+func (webt *WEBTransport) UploadFile(path string, state_dir string, progress func(int64, int64)) (*PBFileOffer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file... %w", err)
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("could not open file... %w", err)
+	}
+	if stat.IsDir() {
+		return nil, fmt.Errorf("%v is a directory", path)
+	}
+	digest, err := hashFile(file)
+	if err != nil {
+		return nil, err
+	}
+	state_path := ""
+	var state *PBUploadState
+	if state_dir != "" {
+		state_path = uploadStatePath(state_dir, webt.host_url, path)
+		state = loadUploadState(state_path)
+	}
+	resumed := state != nil && bytes.Equal(state.GetSha256(), digest) && state.GetSize() == stat.Size()
+	if !resumed {
+		state, err = webt.startUpload(state_path, digest, stat.Size())
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = webt.uploadChunks(file, state, progress)
+	if resumed && errors.Is(err, errBlobNotFound) {
+		// the relay dropped the earlier upload, so start again
+		state, err = webt.startUpload(state_path, digest, stat.Size())
+		if err != nil {
+			return nil, err
+		}
+		err = webt.uploadChunks(file, state, progress)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if state_path != "" {
+		os.Remove(state_path)
+	}
+	return &PBFileOffer{
+		BlobId:    state.GetBlobId(),
+		Name:      filepath.Base(path),
+		Size:      state.GetSize(),
+		Sha256:    state.GetSha256(),
+		Key:       state.GetKey(),
+		ChunkSize: FILE_CHUNK_SIZE,
+	}, nil
+}
+
+// Creates the blob for a new upload, with a new key, and records it.
+func (webt *WEBTransport) startUpload(state_path string, digest []byte, size int64) (*PBUploadState, error) {
+	id, err := webt.createBlob(encryptedFileSize(size, FILE_CHUNK_SIZE))
+	if err != nil {
+		return nil, err
+	}
+	state := &PBUploadState{
+		Url:    webt.host_url,
+		BlobId: id,
+		Key:    GenerateRandomAESKey(),
+		Sha256: digest,
+		Size:   size,
+	}
+	if state_path != "" {
+		err = saveUploadState(state_path, state)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// Encrypts and uploads the chunks the relay doesn't have yet.
+// The relay says where it's up to whenever a chunk is sent to the
+// wrong offset, so the first chunk finds out where to start.
+func (webt *WEBTransport) uploadChunks(file *os.File, state *PBUploadState, progress func(int64, int64)) error {
+	total := encryptedFileSize(state.GetSize(), FILE_CHUNK_SIZE)
+	encrypted_chunk := int64(FILE_CHUNK_SIZE + FILE_CHUNK_OVERHEAD)
+	backoff := NewBackoff(RECONNECT_MIN_DELAY, RECONNECT_MAX_DELAY)
+	buffer := make([]byte, FILE_CHUNK_SIZE)
+	failures := 0
+	var offset int64
+	for offset < total {
+		if offset%encrypted_chunk != 0 {
+			return fmt.Errorf("server has part of a chunk, up to offset %v", offset)
+		}
+		read, err := file.ReadAt(buffer, offset/encrypted_chunk*FILE_CHUNK_SIZE)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("could not read file... %w", err)
+		}
+		chunk, err := AESEncrypt(buffer[:read], state.GetKey())
+		if err != nil {
+			return err
+		}
+		err = webt.putBlobChunk(state.GetBlobId(), offset, chunk)
+		var offset_err *BlobOffsetError
+		switch {
+		case errors.As(err, &offset_err):
+			offset = offset_err.Offset
+		case errors.Is(err, errBlobNotFound), errors.Is(err, errBlobTooLarge):
+			return err
+		case err != nil:
+			failures++
+			if failures >= FILE_TRANSFER_ATTEMPTS {
+				return fmt.Errorf("gave up after %v tries, send the file again to resume... %w", failures, err)
+			}
+			time.Sleep(backoff.Next())
+		default:
+			offset += int64(len(chunk))
+			failures = 0
+			backoff.Reset()
+			progress(offset, total)
+		}
+	}
+	return nil
+}
+
+// Downloads the offered file to out_path, decrypting it as it comes
+// and checking its hash at the end. The file is written to out_path
+// with .part on the end until then, and a download that finds one
+// carries on after its last whole chunk.
+func (webt *WEBTransport) DownloadFile(offer *PBFileOffer, out_path string, progress func(int64, int64)) error {
+	chunk_size := offer.GetChunkSize()
+	if chunk_size <= 0 || chunk_size+FILE_CHUNK_OVERHEAD > MAX_BLOB_CHUNK {
+		return errors.New("file offer has a bad chunk size")
+	}
+	part_path := out_path + ".part"
+	part, err := os.OpenFile(part_path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("could not create file... %w", err)
+	}
+	defer part.Close()
+	stat, err := part.Stat()
+	if err != nil {
+		return fmt.Errorf("could not create file... %w", err)
+	}
+	done := stat.Size() / chunk_size * chunk_size
+	if done > offer.GetSize() {
+		done = 0
+	}
+	err = part.Truncate(done)
+	if err != nil {
+		return fmt.Errorf("could not resume download... %w", err)
+	}
+	backoff := NewBackoff(RECONNECT_MIN_DELAY, RECONNECT_MAX_DELAY)
+	failures := 0
+	for done < offer.GetSize() {
+		var received int64
+		received, err = webt.downloadChunks(offer, part, done, progress)
+		done += received
+		if err == nil {
+			break
+		}
+		if errors.Is(err, errBlobNotFound) || errors.Is(err, errFileDamaged) {
+			return err
+		}
+		if received > 0 {
+			failures = 0
+			backoff.Reset()
+		}
+		failures++
+		if failures >= FILE_TRANSFER_ATTEMPTS {
+			return fmt.Errorf("gave up after %v tries, fetch it again to resume... %w", failures, err)
+		}
+		time.Sleep(backoff.Next())
+	}
+	digest, err := hashFile(part)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, offer.GetSha256()) {
+		part.Close()
+		os.Remove(part_path)
+		return fmt.Errorf("%w, its hash doesn't match", errFileDamaged)
+	}
+	err = part.Close()
+	if err != nil {
+		return fmt.Errorf("could not write file... %w", err)
+	}
+	return os.Rename(part_path, out_path)
+}
+
+// Reads chunks from the blob, starting with the one after done bytes
+// of the file, and writes them to the end of the part file.
+// Returns how many bytes of the file were written.
+func (webt *WEBTransport) downloadChunks(offer *PBFileOffer, part *os.File, done int64, progress func(int64, int64)) (int64, error) {
+	chunk_size := offer.GetChunkSize()
+	encrypted_chunk := chunk_size + FILE_CHUNK_OVERHEAD
+	body, err := webt.getBlob(offer.GetBlobId(), done/chunk_size*encrypted_chunk)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	_, err = part.Seek(done, io.SeekStart)
+	if err != nil {
+		return 0, fmt.Errorf("could not write file... %w", err)
+	}
+	buffer := make([]byte, encrypted_chunk)
+	var received int64
+	for done+received < offer.GetSize() {
+		expected := offer.GetSize() - done - received
+		if expected > chunk_size {
+			expected = chunk_size
+		}
+		chunk := buffer[:expected+FILE_CHUNK_OVERHEAD]
+		_, err = io.ReadFull(body, chunk)
+		if err != nil {
+			return received, fmt.Errorf("download was cut off... %w", err)
+		}
+		plaintext, err := AESDecrypt(chunk, offer.GetKey())
+		if err != nil || int64(len(plaintext)) != expected {
+			return received, errFileDamaged
+		}
+		_, err = part.Write(plaintext)
+		if err != nil {
+			return received, fmt.Errorf("could not write file... %w", err)
+		}
+		received += expected
+		progress(done+received, offer.GetSize())
+	}
+	return received, nil
+}
+
+// Records a file a friend offered so it can be fetched later,
+// and returns what to show for it.
+func (webt *WEBTransport) receiveFile(offer *PBFileOffer, sender string, text string) string {
+	if webt.files_dir == "" || !validBlobID(offer.GetBlobId()) {
+		return text
+	}
+	record := &PBReceivedFile{
+		Offer:          offer,
+		Url:            webt.host_url,
+		TlsFingerprint: webt.tls_fingerprint,
+		Sender:         sender,
+		ReceivedAt:     time.Now().Unix(),
+	}
+	err := saveReceivedFile(webt.files_dir, record)
+	if err != nil {
+		return fmt.Sprintf("%v\nCould not keep the file's details... %v", text, err)
+	}
+	return fmt.Sprintf("%v\nFetch it with: peppermint fetch %v", text, offer.GetBlobId())
+}
+
+func saveReceivedFile(files_dir string, record *PBReceivedFile) error {
+	data, err := proto.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("could not serialize file offer... %w", err)
+	}
+	err = os.MkdirAll(files_dir, 0700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(files_dir, record.GetOffer().GetBlobId()), data, 0600)
+}
+
+func loadReceivedFile(files_dir string, id string) (*PBReceivedFile, error) {
+	if !validBlobID(id) {
+		return nil, fmt.Errorf("%q is not a file ID", id)
+	}
+	data, err := os.ReadFile(filepath.Join(files_dir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no file with ID %v, the reader shows the ID when a file is sent", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read file offer... %w", err)
+	}
+	record := &PBReceivedFile{}
+	err = proto.Unmarshal(data, record)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse file offer... %w", err)
+	}
+	return record, nil
+}
+
+// The offered name, made safe to write in the current directory.
+func safeFileName(name string, id string) string {
+	name = filepath.Base(name)
+	if name == "." || name == ".." || name == string(filepath.Separator) || strings.HasPrefix(name, ".") {
+		return "file-" + id
+	}
+	return name
+}
+
+// Uploads the file to the relay and offers it to the group.
+func (ppmt *Messanger) SendFile(path string) error {
+	file_transport, ok := ppmt.transport.(FileTransport)
+	if !ok {
+		return errors.New("files can only be sent through a relay")
+	}
+	state_dir := ""
+	if ppmt.files_dir != "" {
+		state_dir = filepath.Join(ppmt.files_dir, "uploads")
+	}
+	offer, err := file_transport.UploadFile(path, state_dir, printProgress("Uploading", filepath.Base(path)))
+	if err != nil {
+		return err
+	}
+	ppmt.publishPayload(Payload{
+		text: fmt.Sprintf("Sent a file: %v (%v)", offer.GetName(), formatSize(offer.GetSize())),
+		file: offer,
+	})
+	return nil
+}
+
+// Uploads a file and offers it to the group, for 'peppermint send-file'.
+func SendFileToGroup(config *MessangerConfig, path string) {
+	messanger := ConfigureMessanger(config)
+	messanger.OutboundConnect()
+	err := messanger.SendFile(path)
+	if err != nil {
+		fmt.Println("Could not send", path, "...", err)
+		os.Exit(1)
+	}
+}
+
+// Downloads a file offered to us, for 'peppermint fetch'.
+// It's saved under its own name in the current directory unless
+// another path is given.
+func FetchFile(id string, out_path string) {
+	ParseConfig()
+	record, err := loadReceivedFile(DefaultFilesDir(), id)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	offer := record.GetOffer()
+	if out_path == "" {
+		out_path = safeFileName(offer.GetName(), id)
+	}
+	if _, err := os.Stat(out_path); err == nil {
+		fmt.Println(out_path, "already exists, pass another path with --output")
+		os.Exit(1)
+	}
+	identity, _ := parseIdentity()
+	webt := &WEBTransport{
+		host_url:    record.GetUrl(),
+		identity:    identity,
+		http_client: NewHTTPClient(record.GetTlsFingerprint()),
+	}
+	err = webt.DownloadFile(offer, out_path, printProgress("Downloading", offer.GetName()))
+	if err != nil {
+		fmt.Println("Could not fetch", offer.GetName(), "...", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Saved %v from %v (%v), its hash matches\n", out_path, record.GetSender(), formatSize(offer.GetSize()))
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlobStore(t *testing.T) {
+	store, err := OpenBlobStore(t.TempDir(), 100, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create("alice", 101); !errors.Is(err, errBlobTooLarge) {
+		t.Errorf("expected a blob over the limit to be refused, got %v", err)
+	}
+	id, _ := store.Create("alice", 10)
+	if _, err := store.Append(id, "bill", 0, strings.NewReader("hello")); !errors.Is(err, errBlobNotOwner) {
+		t.Errorf("expected someone else's chunk to be refused, got %v", err)
+	}
+	store.Append(id, "alice", 0, strings.NewReader("hello"))
+	var offset_err *BlobOffsetError
+	if _, err := store.Append(id, "alice", 0, strings.NewReader("hello")); !errors.As(err, &offset_err) || offset_err.Offset != 5 {
+		t.Errorf("expected a chunk at the wrong offset to be told 5, got %v", err)
+	}
+	if _, _, err := store.Open(id); !errors.Is(err, errBlobIncomplete) {
+		t.Errorf("expected an incomplete blob not to open, got %v", err)
+	}
+	if _, err := store.Append(id, "alice", 5, strings.NewReader("world!")); !errors.Is(err, errBlobTooLarge) {
+		t.Errorf("expected a chunk past the size to be refused, got %v", err)
+	}
+	store.Append(id, "alice", 5, strings.NewReader("world"))
+	_, file, err := store.Open(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, _ := io.ReadAll(file)
+	file.Close()
+	if string(contents) != "helloworld" {
+		t.Errorf("unexpected blob contents %q", contents)
+	}
+	if _, _, err := store.Open("../../etc/passwd"); !errors.Is(err, errBlobNotFound) {
+		t.Errorf("expected a bad ID not to open, got %v", err)
+	}
+}
+
+// A file goes up in chunks and comes back down the same,
+// with both ends picking up where they were cut off.
+func TestFileTransfer(t *testing.T) {
+	SetOutput(io.Discard)
+	defer SetOutput(os.Stdout)
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	cs.blobs, _ = OpenBlobStore(t.TempDir(), 0, 0)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	alice := &WEBTransport{host_url: server.URL, identity: NewKeyIdentity(GenerateRandomKey())}
+	bill := &WEBTransport{host_url: server.URL, identity: NewKeyIdentity(GenerateRandomKey())}
+	progress := func(int64, int64) {}
+
+	dir := t.TempDir()
+	contents := make([]byte, FILE_CHUNK_SIZE*2+FILE_CHUNK_SIZE/2)
+	rand.Read(contents)
+	path := filepath.Join(dir, "notes.bin")
+	os.WriteFile(path, contents, 0600)
+
+	// an upload that stopped after its first chunk
+	state_dir := filepath.Join(dir, "uploads")
+	state_path := uploadStatePath(state_dir, server.URL, path)
+	digest := sha256.Sum256(contents)
+	state, err := alice.startUpload(state_path, digest[:], int64(len(contents)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first_chunk, _ := AESEncrypt(contents[:FILE_CHUNK_SIZE], state.GetKey())
+	if err := alice.putBlobChunk(state.GetBlobId(), 0, first_chunk); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := alice.UploadFile(path, state_dir, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offer.GetBlobId() != state.GetBlobId() {
+		t.Error("the upload was not resumed")
+	}
+	if _, err := os.Stat(state_path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("the finished upload is still recorded")
+	}
+
+	// a download that stopped partway through its second chunk
+	out_path := filepath.Join(dir, "fetched.bin")
+	os.WriteFile(out_path+".part", contents[:FILE_CHUNK_SIZE+100], 0600)
+	if err := bill.DownloadFile(offer, out_path, progress); err != nil {
+		t.Fatal(err)
+	}
+	fetched, _ := os.ReadFile(out_path)
+	if !bytes.Equal(fetched, contents) {
+		t.Error("the fetched file doesn't match")
+	}
+
+	offer.Sha256 = make([]byte, sha256.Size)
+	err = bill.DownloadFile(offer, filepath.Join(dir, "bad.bin"), progress)
+	if !errors.Is(err, errFileDamaged) {
+		t.Errorf("expected a file with the wrong hash to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Identities.
+
+An Identity is the key a client acts as. It signs messages, requests
+and prekeys, and recovers message keys that were wrapped for its own
+public key rather than for a prekey. Nothing outside this file touches
+the private key itself, so the key can live somewhere else:
+
+  - a key file, read with ReadExistingKey
+  - a key held by ssh-agent, picked by its SHA256 fingerprint
+
+ssh-agent can sign but can't decrypt. Agent identities sign RSA
+messages with PKCS #1 v1.5, the only RSA signature the agent makes,
+and can only read messages sent to their prekeys. Readers publish
+prekeys every time they connect, so this is only a problem for
+messages from writers that haven't fetched them yet.
+*/
+
+package internal
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+type Identity interface {
+	// The public key, an *rsa.PublicKey or an ed25519.PublicKey.
+	Public() crypto.PublicKey
+	// The algorithm Sign uses.
+	SignatureAlgorithm() PBSignatureAlgorithm
+	// Signs the text. The text is hashed first if the algorithm needs it.
+	Sign(text []byte) ([]byte, error)
+	// Recovers the AES key of a message that was wrapped for
+	// this identity's public key.
+	UnwrapKey(message *Message) ([]byte, error)
+}
+
+// An identity backed by a private key in memory.
+type keyIdentity struct {
+	key crypto.Signer
+}
+
+func NewKeyIdentity(key crypto.Signer) Identity {
+	return &keyIdentity{key: key}
+}
+
+func (identity *keyIdentity) Public() crypto.PublicKey {
+	return identity.key.Public()
+}
+
+func (identity *keyIdentity) SignatureAlgorithm() PBSignatureAlgorithm {
+	return SignatureAlgorithmFor(identity.key)
+}
+
+func (identity *keyIdentity) Sign(text []byte) ([]byte, error) {
+	return SignWithAlgorithm(identity.key, identity.SignatureAlgorithm(), text)
+}
+
+func (identity *keyIdentity) UnwrapKey(message *Message) ([]byte, error) {
+	switch private_key := identity.key.(type) {
+	case *rsa.PrivateKey:
+		if message.key_algorithm != PBKeyAlgorithm_KEY_RSA_OAEP {
+			return nil, fmt.Errorf("message was encrypted with %v, not for an RSA key", message.key_algorithm)
+		}
+		aes_key, err := RSADecrypt(private_key, message.aes_key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt AES key... %w", err)
+		}
+		return aes_key, nil
+	case ed25519.PrivateKey:
+		if message.key_algorithm != PBKeyAlgorithm_KEY_X25519_IDENTITY {
+			return nil, fmt.Errorf("message was encrypted with %v, not for an Ed25519 key", message.key_algorithm)
+		}
+		x25519_public, err := X25519PublicFromEd25519(private_key.Public().(ed25519.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+		x25519_private := X25519PrivateFromEd25519(private_key)
+		return message.unwrapX25519Key(func(public_key []byte) []byte {
+			if string(public_key) == string(x25519_public) {
+				return x25519_private
+			}
+			return nil
+		})
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", identity.key)
+	}
+}
+
+// An identity whose private key stays in ssh-agent.
+type agentIdentity struct {
+	agent      agent.ExtendedAgent
+	key        ssh.PublicKey
+	public_key crypto.PublicKey
+}
+
+// Connects to the ssh-agent at $SSH_AUTH_SOCK and picks the key with
+// the given fingerprint, as printed by `ssh-add -l` ("SHA256:...").
+func NewAgentIdentity(fingerprint string) (Identity, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("SSH_AUTH_SOCK is not set, is ssh-agent running?")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to ssh-agent... %w", err)
+	}
+	return agentIdentityFor(agent.NewClient(conn), fingerprint)
+}
+
+func agentIdentityFor(client agent.ExtendedAgent, fingerprint string) (Identity, error) {
+	keys, err := client.List()
+	if err != nil {
+		return nil, fmt.Errorf("could not list ssh-agent keys... %w", err)
+	}
+	if !strings.HasPrefix(fingerprint, "SHA256:") {
+		fingerprint = "SHA256:" + fingerprint
+	}
+	for _, agent_key := range keys {
+		if ssh.FingerprintSHA256(agent_key) != fingerprint {
+			continue
+		}
+		key, err := ssh.ParsePublicKey(agent_key.Marshal())
+		if err != nil {
+			return nil, fmt.Errorf("could not parse ssh-agent key %v... %w", fingerprint, err)
+		}
+		crypto_key, ok := key.(ssh.CryptoPublicKey)
+		if !ok {
+			return nil, fmt.Errorf("ssh-agent key %v has an unsupported type", fingerprint)
+		}
+		public_key := crypto_key.CryptoPublicKey()
+		if _, err := KeyType(public_key); err != nil {
+			return nil, fmt.Errorf("ssh-agent key %v... %w", fingerprint, err)
+		}
+		return &agentIdentity{agent: client, key: key, public_key: public_key}, nil
+	}
+	return nil, fmt.Errorf("ssh-agent has no key with fingerprint %v", fingerprint)
+}
+
+func (identity *agentIdentity) Public() crypto.PublicKey {
+	return identity.public_key
+}
+
+func (identity *agentIdentity) SignatureAlgorithm() PBSignatureAlgorithm {
+	if _, ok := identity.public_key.(ed25519.PublicKey); ok {
+		return PBSignatureAlgorithm_SIGNATURE_ED25519
+	}
+	return PBSignatureAlgorithm_SIGNATURE_RSA_PKCS1V15
+}
+
+// The agent hashes the text itself. RSA keys are asked
+// for rsa-sha2-256, which is PKCS #1 v1.5 over SHA-256.
+func (identity *agentIdentity) Sign(text []byte) ([]byte, error) {
+	var flags agent.SignatureFlags
+	if _, ok := identity.public_key.(*rsa.PublicKey); ok {
+		flags = agent.SignatureFlagRsaSha256
+	}
+	signature, err := identity.agent.SignWithFlags(identity.key, text, flags)
+	if err != nil {
+		return nil, fmt.Errorf("ssh-agent could not sign... %w", err)
+	}
+	return signature.Blob, nil
+}
+
+func (identity *agentIdentity) UnwrapKey(message *Message) ([]byte, error) {
+	return nil, errors.New("ssh-agent keys can't decrypt, this message needs to be sent to one of our prekeys")
+}
+
+// A name for the prekey file of an agent identity, since it has no key
+// file to sit next to.
+func AgentPrekeyFileName(fingerprint string) string {
+	fingerprint = strings.TrimPrefix(fingerprint, "SHA256:")
+	raw, err := base64.RawStdEncoding.DecodeString(fingerprint)
+	if err != nil {
+		return "ssh_agent.prekeys"
+	}
+	return "ssh_agent_" + base64.RawURLEncoding.EncodeToString(raw) + ".prekeys"
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Adds the key to an in-memory agent and returns the agent identity for it.
+func newAgentIdentity(t *testing.T, key_type string) Identity {
+	key, _ := GenerateKey(key_type)
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	ssh_key, _ := ssh.NewPublicKey(key.Public())
+	identity, err := agentIdentityFor(keyring.(agent.ExtendedAgent), ssh.FingerprintSHA256(ssh_key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity
+}
+
+func TestAgentIdentity(t *testing.T) {
+	recipient := NewKeyIdentity(GenerateRandomKey())
+	for _, key_type := range []string{KEY_TYPE_RSA, KEY_TYPE_ED25519} {
+		sender := newAgentIdentity(t, key_type)
+		message := Message{content: []byte("Hello"), public_key: PublicKeyToPEM(sender.Public())}
+		message.Encrypt(recipient.Public())
+		message.Sign(sender)
+		if err := message.Verify(recipient.Public()); err != nil {
+			t.Errorf("%v agent signature did not verify: %v", key_type, err)
+		}
+		// agent keys sign prekeys, and read messages through them
+		store, _ := OpenPrekeyStore(filepath.Join(t.TempDir(), "agent.prekeys"))
+		prekey, err := store.Refresh(sender)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyPrekey(sender.Public(), prekey); err != nil {
+			t.Errorf("%v agent prekey did not verify: %v", key_type, err)
+		}
+		reply := Message{content: []byte("Hi")}
+		reply.EncryptForPrekeys(sender.Public(), [][]byte{prekey.PublicKey})
+		if err := reply.DecryptWithPrekeys(store); err != nil || string(reply.content) != "Hi" {
+			t.Errorf("%v agent could not read a prekey message: %v", key_type, err)
+		}
+		reply = Message{content: []byte("Hi")}
+		reply.Encrypt(sender.Public())
+		if reply.Decrypt(sender) == nil {
+			t.Errorf("%v agent decrypted with its long-term key", key_type)
+		}
+	}
+	if _, err := agentIdentityFor(agent.NewKeyring().(agent.ExtendedAgent), "SHA256:nothing"); err == nil {
+		t.Error("found a key the agent doesn't have")
+	}
+}
+
+// An agent identity gets its prekey directory made and a prekey to read
+// with, and is refused if the prekey store can't be used.
+func TestAgentPrekeyFile(t *testing.T) {
+	identity := newAgentIdentity(t, KEY_TYPE_ED25519)
+	dir := filepath.Join(t.TempDir(), ".peppermint")
+	prekey_file, err := agentPrekeyFile(identity, dir, "SHA256:abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenPrekeyStore(prekey_file)
+	if err != nil || len(store.prekeys) != 1 {
+		t.Errorf("expected the agent to have a prekey, got %v (%v)", store, err)
+	}
+	blocked := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocked, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agentPrekeyFile(identity, blocked, "SHA256:abc"); err == nil || !strings.Contains(err.Error(), "can't decrypt") {
+		t.Errorf("expected an unusable prekey store to be refused, got %v", err)
+	}
+}
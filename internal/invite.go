@@ -0,0 +1,323 @@
+/*
+Invites.
+
+'peppermint invite' prints a token that holds everything someone needs
+to join a group: the server URL (and certificate fingerprint), the
+group's name and ID, and the inviter's public key and name. The token
+is signed by the inviter and expires.
+
+'peppermint join' checks the token, adds the group and the inviter to
+the config, and sends an introduction back to the inviter over the
+relay. The introduction carries the invite, so the inviter's reader can
+tell it came from one of their own invites and add the new member to
+their config in turn. Anyone holding the token can join until it
+expires, so share it the way you would share the group itself.
+
+Relays with access groups still have to let the new key in.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/proto"
+)
+
+const INVITE_PREFIX = "ppmt-invite:"
+
+// How long an invite is good for unless told otherwise.
+const DEFAULT_INVITE_TTL = 7 * 24 * time.Hour
+
+// How long after an invite expires an introduction that used it is
+// still taken, for the time it spent waiting in the mailbox.
+const INVITE_DELIVERY_GRACE = 15 * time.Minute
+
+// Builds the bytes that get signed for an invite, in the same
+// length prefixed form as messages.
+func inviteSigningText(invite *PBInvite) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("peppermint-invite-v1")
+	for _, field := range [][]byte{
+		binary.BigEndian.AppendUint32(nil, uint32(invite.GetSignatureAlgorithm())),
+		[]byte(invite.GetUrl()),
+		[]byte(invite.GetGroupName()),
+		[]byte(invite.GetGroupId()),
+		invite.GetPublicKey(),
+		[]byte(invite.GetName()),
+		binary.BigEndian.AppendUint64(nil, uint64(invite.GetExpiresAt())),
+		[]byte(invite.GetTlsFingerprint()),
+	} {
+		buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+		buffer.Write(field)
+	}
+	return buffer.Bytes()
+}
+
+// Makes a signed invite token for the group, good for the given time.
+func CreateInvite(identity Identity, config *MessangerConfig, name string, ttl time.Duration) (string, error) {
+	invite := &PBInvite{
+		Url:                config.URL,
+		GroupName:          config.Name,
+		GroupId:            config.GroupID,
+		PublicKey:          PublicKeyToBytes(identity.Public()),
+		Name:               name,
+		ExpiresAt:          time.Now().Add(ttl).Unix(),
+		TlsFingerprint:     config.TLSFingerprint,
+		SignatureAlgorithm: identity.SignatureAlgorithm(),
+	}
+	signature, err := identity.Sign(inviteSigningText(invite))
+	if err != nil {
+		return "", fmt.Errorf("could not sign invite... %w", err)
+	}
+	invite.Signature = signature
+	data, err := proto.Marshal(invite)
+	if err != nil {
+		return "", fmt.Errorf("could not serialize invite... %w", err)
+	}
+	return INVITE_PREFIX + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Checks that the invite was signed by the key in it and hadn't
+// expired at the given time.
+func VerifyInvite(invite *PBInvite, at time.Time) error {
+	pub_key, err := BytesToPublicKey(invite.GetPublicKey())
+	if err != nil {
+		return fmt.Errorf("invite has a bad public key... %w", err)
+	}
+	if !VerifyText(pub_key, invite.GetSignatureAlgorithm(), inviteSigningText(invite), invite.GetSignature()) {
+		return errors.New("invite signature does not match the inviter's key")
+	}
+	if at.After(time.Unix(invite.GetExpiresAt(), 0)) {
+		return fmt.Errorf("invite expired at %v", time.Unix(invite.GetExpiresAt(), 0).Format("Jan 2 15:04"))
+	}
+	if invite.GetUrl() == "" || invite.GetGroupId() == "" {
+		return errors.New("invite is missing the server or the group")
+	}
+	return nil
+}
+
+// Decodes an invite token and checks it.
+func ParseInvite(token string) (*PBInvite, error) {
+	token = strings.TrimSpace(token)
+	if !strings.HasPrefix(token, INVITE_PREFIX) {
+		return nil, fmt.Errorf("not an invite, invites start with %v", INVITE_PREFIX)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(token, INVITE_PREFIX))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode invite... %w", err)
+	}
+	invite := &PBInvite{}
+	err = proto.Unmarshal(data, invite)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse invite... %w", err)
+	}
+	return invite, VerifyInvite(invite, time.Now())
+}
+
+var bare_toml_key = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Quotes a TOML key if it needs it.
+func tomlKey(key string) string {
+	if bare_toml_key.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// Writes a TOML basic string, dropping control characters.
+func tomlString(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	return `"` + strings.ReplaceAll(text, `"`, `\"`) + `"`
+}
+
+// The config table for a group joined with the invite.
+func groupConfigText(group string, invite *PBInvite) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "\n[%v]\n", tomlKey(group))
+	fmt.Fprintf(&text, "url = %v\n", tomlString(invite.GetUrl()))
+	fmt.Fprintf(&text, "group_id = %v\n", tomlString(invite.GetGroupId()))
+	if invite.GetTlsFingerprint() != "" {
+		fmt.Fprintf(&text, "tls_fingerprint = %v\n", tomlString(invite.GetTlsFingerprint()))
+	}
+	return text.String()
+}
+
+// The config entry for a user in a group.
+func userConfigText(group string, name string, pub_key crypto.PublicKey) string {
+	return fmt.Sprintf(
+		"\n[[%v.users]]\nname = %v\nkey = '''\n%s'''\n",
+		tomlKey(group), tomlString(name), PublicKeyToPEM(pub_key),
+	)
+}
+
+// Adds the text to the end of the config file.
+func appendToConfig(config_file string, text string) error {
+	file, err := os.OpenFile(config_file, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("could not open config... %w", err)
+	}
+	_, err = file.WriteString(text)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("could not update config... %w", err)
+	}
+	return file.Close()
+}
+
+// A name for someone who didn't give one.
+func nameOrDefault(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "new friend"
+	}
+	return name
+}
+
+// The name to put on invites and introductions when none is given.
+func DefaultName() string {
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "new friend"
+}
+
+// Prints an invite to the group, for 'peppermint invite'.
+func InviteToGroup(group string, name string, ttl time.Duration) {
+	config := ParseConfigWithViper(group)
+	if config.URL == "" {
+		fmt.Println("Group", group, "has no url in the config")
+		os.Exit(1)
+	}
+	token, err := CreateInvite(config.Identity, config, nameOrDefault(name), ttl)
+	CheckErrFatal(err)
+	fmt.Printf("Invite to %v, good until %v:\n\n%v\n\n", config.Name, time.Now().Add(ttl).Format("Jan 2 15:04"), token)
+	fmt.Println("Keep 'peppermint read' running to add whoever joins to your config.")
+}
+
+// Joins the group in the invite, for 'peppermint join'. The group is
+// added to the config under the inviter's name for it, unless another
+// is given, and the inviter is sent our key.
+func JoinGroup(token string, group string, name string) {
+	invite, err := ParseInvite(token)
+	if err != nil {
+		fmt.Println("Could not use invite:", err)
+		os.Exit(1)
+	}
+	ParseConfig()
+	identity, prekey_file := parseIdentity()
+	inviter_key, err := BytesToPublicKey(invite.GetPublicKey())
+	CheckErrFatal(err)
+	if group == "" {
+		group = invite.GetGroupName()
+	}
+	group = strings.ToLower(group)
+	config_file := viper.ConfigFileUsed()
+	var existing MessangerConfig
+	if viper.IsSet(group) {
+		err = viper.UnmarshalKey(group, &existing)
+		CheckErrFatal(err)
+	} else {
+		err = appendToConfig(config_file, groupConfigText(group, invite))
+		CheckErrFatal(err)
+	}
+	if !hasUser(existing.Users, inviter_key) {
+		err = appendToConfig(config_file, userConfigText(group, nameOrDefault(invite.GetName()), inviter_key))
+		CheckErrFatal(err)
+	}
+	fmt.Printf("Added group %v and %v to %v\n", group, nameOrDefault(invite.GetName()), config_file)
+
+	webt := &WEBTransport{
+		host_url:    invite.GetUrl(),
+		identity:    identity,
+		prekey_file: prekey_file,
+		http_client: NewHTTPClient(invite.GetTlsFingerprint()),
+	}
+	if err := webt.publishPrekey(); err != nil {
+		fmt.Println("Could not publish a prekey:", err)
+	}
+	inviter := FriendDetail{public_key: inviter_key, name: nameOrDefault(invite.GetName())}
+	payload := Payload{
+		message_id:   RandomID(),
+		timestamp:    time.Now().UnixMilli(),
+		group_id:     invite.GetGroupId(),
+		introduction: &Introduction{invite: invite, name: nameOrDefault(name)},
+	}
+	content, err := SealPayload(payload, identity, inviter_key, webt.Prekeys(&inviter))
+	if err == nil {
+		err = webt.Writer(&inviter, content)
+	}
+	if err != nil {
+		fmt.Println("Could not send your key to", inviter.name, "...", err)
+		os.Exit(1)
+	}
+	fmt.Println("Sent your key to", inviter.name)
+}
+
+// Whether the key is one of the users'.
+func hasUser(users []RecipientConfig, pub_key crypto.PublicKey) bool {
+	for _, user := range users {
+		user_key, err := ParsePublicKey([]byte(user.Key))
+		if err == nil && PublicKeyToString(user_key) == PublicKeyToString(pub_key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds someone who joined with one of our invites to the group, in
+// memory and in the config. The invite has to be ours, and must not
+// have expired by the time we read the introduction, give or take
+// INVITE_DELIVERY_GRACE. The time the joiner signed is theirs to
+// choose, so it isn't trusted.
+func (webt *WEBTransport) handleIntroduction(introduction *Introduction, sender crypto.PublicKey, friend_map FriendDetailMap) {
+	invite := introduction.invite
+	if invite == nil || VerifyInvite(invite, time.Now().Add(-INVITE_DELIVERY_GRACE)) != nil {
+		fmt.Fprintf(output, "%v Ignored someone joining with a bad or expired invite\n", X_MARK)
+		return
+	}
+	if !bytes.Equal(invite.GetPublicKey(), PublicKeyToBytes(webt.identity.Public())) {
+		fmt.Fprintf(output, "%v Ignored someone joining with an invite we didn't make\n", X_MARK)
+		return
+	}
+	friends := friend_map
+	if invite.GetGroupId() != webt.group_id {
+		group, ok := webt.other_groups[invite.GetGroupId()]
+		if !ok {
+			fmt.Fprintf(output, "%v Someone joined group %q, which is no longer in the config\n", X_MARK, invite.GetGroupId())
+			return
+		}
+		friends = group.friends
+	}
+	sender_key := PublicKeyToString(sender)
+	if _, ok := friends[sender_key]; ok {
+		return
+	}
+	name := nameOrDefault(introduction.name)
+	friends[sender_key] = FriendDetail{public_key: sender, name: name}
+	if webt.config_file == "" {
+		return
+	}
+	err := appendToConfig(webt.config_file, userConfigText(invite.GetGroupName(), name, sender))
+	if err != nil {
+		fmt.Fprintln(output, "Could not add", name, "to the config...", err)
+		return
+	}
+	PrintConnectionStatus(fmt.Sprintf("%v joined %v with your invite and was added to your config", name, invite.GetGroupName()))
+}
@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestInviteToken(t *testing.T) {
+	alice := NewKeyIdentity(GenerateRandomKey())
+	config := &MessangerConfig{URL: "https://relay.example.com", Name: "friends", GroupID: "friends-id", TLSFingerprint: "abcd"}
+	token, err := CreateInvite(alice, config, "Alice", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invite, err := ParseInvite(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invite.GetGroupId() != "friends-id" || invite.GetName() != "Alice" || invite.GetTlsFingerprint() != "abcd" {
+		t.Errorf("invite did not round trip: %v", invite)
+	}
+	if VerifyInvite(invite, time.Now().Add(2*time.Hour)) == nil {
+		t.Error("an expired invite was accepted")
+	}
+	invite.GroupId = "enemies"
+	if VerifyInvite(invite, time.Now()) == nil {
+		t.Error("an invite was accepted after the group changed")
+	}
+	if _, err := ParseInvite("hello"); err == nil {
+		t.Error("a token without the prefix was accepted")
+	}
+}
+
+// The config text written for a join parses back to the same group and user.
+func TestJoinConfigText(t *testing.T) {
+	bill, _ := GenerateKey(KEY_TYPE_ED25519)
+	invite := &PBInvite{Url: "https://relay.example.com", GroupId: "the \"gang\"", TlsFingerprint: "abcd"}
+	text := groupConfigText("the gang", invite) + userConfigText("the gang", "Bill \"B\" \\o/", bill.Public())
+	config_file := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(config_file, []byte(text), 0600)
+	parsed := viper.New()
+	parsed.SetConfigFile(config_file)
+	if err := parsed.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	var group MessangerConfig
+	parsed.UnmarshalKey("the gang", &group)
+	if group.URL != invite.Url || group.GroupID != invite.GroupId || group.TLSFingerprint != "abcd" {
+		t.Errorf("group did not parse back: %+v", group)
+	}
+	if len(group.Users) != 1 || group.Users[0].Name != "Bill \"B\" \\o/" || !hasUser(group.Users, bill.Public()) {
+		t.Errorf("user did not parse back: %+v", group.Users)
+	}
+}
+
+// The inviter's reader adds whoever joins with their invite.
+func TestHandleIntroduction(t *testing.T) {
+	var printed bytes.Buffer
+	SetOutput(&printed)
+	defer SetOutput(os.Stdout)
+	alice := NewKeyIdentity(GenerateRandomKey())
+	bill := NewKeyIdentity(GenerateRandomKey())
+	config_file := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(config_file, []byte("[friends]\nurl = \"https://relay.example.com\"\n"), 0600)
+	config := &MessangerConfig{URL: "https://relay.example.com", Name: "friends", GroupID: "friends"}
+	token, _ := CreateInvite(alice, config, "Alice", time.Hour)
+	invite, _ := ParseInvite(token)
+	webt := &WEBTransport{identity: alice, group_id: "friends", config_file: config_file}
+	friend_map := FriendDetailMap{}
+
+	introduce := func(sender Identity, invite *PBInvite, sent_at time.Time) {
+		payload := Payload{
+			message_id:   RandomID(),
+			timestamp:    sent_at.UnixMilli(),
+			group_id:     "friends",
+			introduction: &Introduction{invite: invite, name: "Bill"},
+		}
+		sealed, err := SealPayload(payload, sender, alice.Public(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		webt.handleMessage(sealed, PublicKeyToString(alice.Public()), friend_map)
+	}
+	introduce(bill, invite, time.Now())
+	if friend, ok := friend_map[PublicKeyToString(bill.Public())]; !ok || friend.name != "Bill" {
+		t.Fatal("Bill was not added to the group")
+	}
+	// a second introduction doesn't add Bill twice
+	introduce(bill, invite, time.Now())
+	contents, _ := os.ReadFile(config_file)
+	if strings.Count(string(contents), "[[friends.users]]") != 1 {
+		t.Errorf("expected Bill in the config once, got:\n%s", contents)
+	}
+	// an invite from someone else doesn't let anyone in
+	carol := NewKeyIdentity(GenerateRandomKey())
+	other_token, _ := CreateInvite(carol, config, "Carol", time.Hour)
+	other_invite, _ := ParseInvite(other_token)
+	introduce(carol, other_invite, time.Now())
+	if _, ok := friend_map[PublicKeyToString(carol.Public())]; ok {
+		t.Error("someone joined with an invite we didn't make")
+	}
+	// an expired invite can't be used by backdating the introduction
+	dave := NewKeyIdentity(GenerateRandomKey())
+	expired_token, _ := CreateInvite(alice, config, "Alice", -time.Hour)
+	expired_invite, _ := ParseInvite(expired_token)
+	introduce(dave, expired_invite, time.Now().Add(-2*time.Hour))
+	if _, ok := friend_map[PublicKeyToString(dave.Public())]; ok {
+		t.Error("someone joined with an expired invite")
+	}
+}
@@ -0,0 +1,110 @@
+/*
+A reader for other programs, for 'peppermint read --format jsonl'.
+
+Instead of boxes, the reader writes one JSON object per line to
+stdout for everything that happens, so group traffic can be piped
+into jq, a log processor or a bot. Every event has a "type":
+
+	message     a message was read; it has passed signature checks
+	error       a message couldn't be read, or the connection failed
+	connection  the connection to the server changed
+	delivery    the status of a message we sent changed
+
+Anything else the reader has to say goes to stderr, so stdout is
+only ever JSON.
+*/
+
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Timestamps are UTC with milliseconds, which sort as text.
+const JSON_TIME_FORMAT = "2006-01-02T15:04:05.000Z07:00"
+
+type jsonEvent struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	MessageID string `json:"message_id,omitempty"`
+	GroupID   string `json:"group_id,omitempty"`
+	Group     string `json:"group,omitempty"`
+	Sender    string `json:"sender,omitempty"`
+	// the full SHA-256 fingerprint of the sender's key
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// only on messages, where it's always there
+	Verified *bool  `json:"verified,omitempty"`
+	FromSelf bool   `json:"from_self,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Status   string `json:"status,omitempty"`
+}
+
+// Writes what the reader receives as JSON lines.
+type JSONDisplay struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	// group names from the config, by group ID
+	groups map[string]string
+}
+
+// Writes events to the writer, naming groups as the config does.
+func NewJSONDisplay(writer io.Writer, config *MessangerConfig) *JSONDisplay {
+	groups := map[string]string{config.GroupID: config.Name}
+	for _, group := range config.OtherGroups {
+		groups[group.GroupID] = group.Name
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	return &JSONDisplay{encoder: encoder, groups: groups}
+}
+
+func (jd *JSONDisplay) write(event jsonEvent) {
+	jd.mutex.Lock()
+	defer jd.mutex.Unlock()
+	if event.Timestamp == "" {
+		event.Timestamp = time.Now().UTC().Format(JSON_TIME_FORMAT)
+	}
+	if event.GroupID != "" {
+		event.Group = jd.groups[event.GroupID]
+	}
+	jd.encoder.Encode(event)
+}
+
+func (jd *JSONDisplay) ShowMessage(message DisplayedMessage) {
+	verified := message.verified
+	jd.write(jsonEvent{
+		Type:        "message",
+		Timestamp:   time.UnixMilli(message.timestamp).UTC().Format(JSON_TIME_FORMAT),
+		MessageID:   message.message_id,
+		GroupID:     message.group_id,
+		Sender:      message.sender,
+		Fingerprint: message.fingerprint,
+		Verified:    &verified,
+		FromSelf:    message.from_self,
+		Text:        message.text,
+	})
+}
+
+func (jd *JSONDisplay) ShowError(problem DisplayedError) {
+	jd.write(jsonEvent{
+		Type:        "error",
+		Kind:        problem.kind,
+		Error:       problem.text,
+		GroupID:     problem.group_id,
+		Sender:      problem.sender,
+		Fingerprint: problem.fingerprint,
+	})
+}
+
+func (jd *JSONDisplay) ShowConnectionStatus(status string) {
+	jd.write(jsonEvent{Type: "connection", Status: status})
+}
+
+func (jd *JSONDisplay) ShowDeliveryStatus(message_id string, summary string) {
+	jd.write(jsonEvent{Type: "delivery", MessageID: message_id, Status: summary})
+}
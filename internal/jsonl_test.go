@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// Messages and problems reading them come out as one JSON object a line.
+func TestJSONDisplay(t *testing.T) {
+	var events bytes.Buffer
+	SetOutput(io.Discard)
+	defer SetOutput(os.Stdout)
+	SetDisplay(NewJSONDisplay(&events, &MessangerConfig{GroupID: "friends", Name: "Friends"}))
+	defer SetDisplay(nil)
+	self := GenerateRandomKey()
+	bill_key := GenerateRandomKey()
+	stranger := GenerateRandomKey()
+	bill := FriendDetail{public_key: &bill_key.PublicKey, name: "Bill", verified: true}
+	friend_map := createFriendPubKeyMap([]FriendDetail{bill})
+	webt := &WEBTransport{identity: NewKeyIdentity(self), group_id: "friends"}
+
+	sent_at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	message := Payload{message_id: "m1", timestamp: sent_at.UnixMilli(), group_id: "friends", text: "Hi <there>"}
+	for _, sender := range []Identity{NewKeyIdentity(bill_key), NewKeyIdentity(stranger)} {
+		sealed, err := SealPayload(message, sender, &self.PublicKey, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		webt.handleMessage(sealed, "", friend_map)
+		message.message_id = "m2"
+	}
+	webt.handleMessage([]byte("not a message"), "", friend_map)
+
+	var parsed []map[string]interface{}
+	decoder := json.NewDecoder(&events)
+	for decoder.More() {
+		event := map[string]interface{}{}
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatal(err)
+		}
+		parsed = append(parsed, event)
+	}
+	if len(parsed) != 3 {
+		t.Fatalf("expected three events, got %v", parsed)
+	}
+	expected := map[string]interface{}{
+		"type":        "message",
+		"message_id":  "m1",
+		"group":       "Friends",
+		"sender":      "Bill",
+		"fingerprint": KeyFingerprint(&bill_key.PublicKey),
+		"verified":    true,
+		"timestamp":   "2024-05-01T12:30:00.000Z",
+		"text":        "Hi <there>",
+	}
+	for field, value := range expected {
+		if parsed[0][field] != value {
+			t.Errorf("expected the message's %v to be %v, got %v", field, value, parsed[0][field])
+		}
+	}
+	if parsed[1]["type"] != "error" || parsed[1]["kind"] != READ_ERROR_UNKNOWN_SENDER ||
+		parsed[1]["fingerprint"] != KeyFingerprint(&stranger.PublicKey) {
+		t.Errorf("unexpected event for an unknown sender: %v", parsed[1])
+	}
+	if parsed[2]["type"] != "error" || parsed[2]["kind"] != READ_ERROR_MALFORMED {
+		t.Errorf("unexpected event for a malformed message: %v", parsed[2])
+	}
+}
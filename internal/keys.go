@@ -0,0 +1,240 @@
+/*
+Key types.
+
+An identity can be an RSA key or an Ed25519 key, and a group can mix
+both. Everything that signs or checks a signature records which
+algorithm was used, so keys of either type can verify each other's
+messages:
+
+  - RSA keys sign messages with RSA-PSS. PKCS #1 v1.5 signatures are
+    still accepted where no algorithm is given.
+  - Ed25519 keys sign with Ed25519.
+
+Messages for an RSA key are wrapped with RSA-OAEP, as before.
+Messages for an Ed25519 key are wrapped with X25519, using the
+Montgomery form of the Ed25519 key, so the same key pair both signs
+and decrypts. Either kind of key can also publish prekeys.
+
+Key files can be encrypted with a passphrase, in the OpenSSH format
+ssh-keygen uses (bcrypt KDF and AES-256-CTR).
+*/
+
+package internal
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Wrong passphrases are retried this many times before giving up.
+const PASSPHRASE_ATTEMPTS = 3
+
+const (
+	KEY_TYPE_RSA     = "rsa"
+	KEY_TYPE_ED25519 = "ed25519"
+)
+
+// Generates a new private key of the given type.
+func GenerateKey(key_type string) (crypto.Signer, error) {
+	switch key_type {
+	case KEY_TYPE_RSA:
+		return GenerateRandomKey(), nil
+	case KEY_TYPE_ED25519:
+		_, private_key, err := ed25519.GenerateKey(rand.Reader)
+		return private_key, err
+	default:
+		return nil, fmt.Errorf("unknown key type %q, use %v or %v", key_type, KEY_TYPE_RSA, KEY_TYPE_ED25519)
+	}
+}
+
+// Returns the key type of a public key, or an error for types
+// peppermint doesn't support.
+func KeyType(pub_key crypto.PublicKey) (string, error) {
+	switch pub_key.(type) {
+	case *rsa.PublicKey:
+		return KEY_TYPE_RSA, nil
+	case ed25519.PublicKey:
+		return KEY_TYPE_ED25519, nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub_key)
+	}
+}
+
+// Accepts the key types peppermint supports as private keys.
+// OpenSSH key files parse to a pointer for Ed25519 keys.
+func asSigner(key interface{}) (crypto.Signer, error) {
+	switch private_key := key.(type) {
+	case *rsa.PrivateKey:
+		return private_key, nil
+	case ed25519.PrivateKey:
+		return private_key, nil
+	case *ed25519.PrivateKey:
+		return *private_key, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// Encodes a private key as PEM. RSA keys keep the PKCS #1 form
+// peppermint has always written, and Ed25519 keys use PKCS #8.
+func MarshalPrivateKey(key crypto.Signer) ([]byte, error) {
+	switch private_key := key.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(private_key),
+		}), nil
+	case ed25519.PrivateKey:
+		der, err := x509.MarshalPKCS8PrivateKey(private_key)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal private key... %w", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// Encodes a private key as an OpenSSH key file encrypted with the
+// passphrase. An empty passphrase gives the same file as MarshalPrivateKey.
+func MarshalEncryptedPrivateKey(key crypto.Signer, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return MarshalPrivateKey(key)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(key, "", passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt private key... %w", err)
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// Parses a private key file. If the file is encrypted, the passphrase
+// func is called for the passphrase, again after each wrong one.
+func ParsePrivateKey(key_bytes []byte, passphrase func() ([]byte, error)) (crypto.Signer, error) {
+	key, err := ssh.ParseRawPrivateKey(key_bytes)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		for attempt := 0; attempt < PASSPHRASE_ATTEMPTS; attempt++ {
+			secret, prompt_err := passphrase()
+			if prompt_err != nil {
+				return nil, prompt_err
+			}
+			key, err = ssh.ParseRawPrivateKeyWithPassphrase(key_bytes, secret)
+			if err != x509.IncorrectPasswordError {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key... %w", err)
+	}
+	return asSigner(key)
+}
+
+// Reports whether a private key file needs a passphrase.
+func IsEncryptedKey(key_bytes []byte) bool {
+	_, err := ssh.ParseRawPrivateKey(key_bytes)
+	var missing *ssh.PassphraseMissingError
+	return errors.As(err, &missing)
+}
+
+// The algorithm a key signs with.
+func SignatureAlgorithmFor(key crypto.Signer) PBSignatureAlgorithm {
+	if _, ok := key.Public().(ed25519.PublicKey); ok {
+		return PBSignatureAlgorithm_SIGNATURE_ED25519
+	}
+	return PBSignatureAlgorithm_SIGNATURE_RSA_PSS
+}
+
+// Signs the text with the given algorithm, which must suit the key.
+func SignWithAlgorithm(key crypto.Signer, algorithm PBSignatureAlgorithm, text []byte) ([]byte, error) {
+	switch algorithm {
+	case PBSignatureAlgorithm_SIGNATURE_ED25519:
+		return key.Sign(rand.Reader, text, crypto.Hash(0))
+	case PBSignatureAlgorithm_SIGNATURE_RSA_PSS:
+		digest := sha256.Sum256(text)
+		return key.Sign(rand.Reader, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+	case PBSignatureAlgorithm_SIGNATURE_RSA_PKCS1V15:
+		digest := sha256.Sum256(text)
+		return key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	default:
+		return nil, fmt.Errorf("unknown signature algorithm %v", algorithm)
+	}
+}
+
+// Checks a signature made with the given algorithm.
+// The algorithm has to match the type of the key.
+func VerifyText(pub_key crypto.PublicKey, algorithm PBSignatureAlgorithm, text []byte, signature []byte) bool {
+	switch key := pub_key.(type) {
+	case ed25519.PublicKey:
+		return algorithm == PBSignatureAlgorithm_SIGNATURE_ED25519 && ed25519.Verify(key, text, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(text)
+		switch algorithm {
+		case PBSignatureAlgorithm_SIGNATURE_RSA_PSS:
+			options := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+			return rsa.VerifyPSS(key, crypto.SHA256, digest[:], signature, options) == nil
+		case PBSignatureAlgorithm_SIGNATURE_RSA_PKCS1V15:
+			return RSAVerify(key, text, signature)
+		}
+	}
+	return false
+}
+
+// The prime 2^255 - 19 that Curve25519 and Ed25519 are defined over.
+var curve25519_prime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// Converts an Ed25519 public key to the X25519 public key of the same
+// key pair, using the birational map u = (1 + y) / (1 - y).
+func X25519PublicFromEd25519(pub_key ed25519.PublicKey) ([]byte, error) {
+	if len(pub_key) != ed25519.PublicKeySize {
+		return nil, errors.New("Ed25519 public key is the wrong size")
+	}
+	// the point is stored as little endian y, with the sign of x in the top bit
+	big_endian := make([]byte, ed25519.PublicKeySize)
+	for i, b := range pub_key {
+		big_endian[len(pub_key)-1-i] = b
+	}
+	big_endian[0] &= 0x7f
+	y := new(big.Int).SetBytes(big_endian)
+	if y.Cmp(curve25519_prime) >= 0 {
+		return nil, errors.New("Ed25519 public key is not a valid point")
+	}
+	one := big.NewInt(1)
+	numerator := new(big.Int).Add(one, y)
+	denominator := new(big.Int).Sub(one, y)
+	denominator.Mod(denominator, curve25519_prime)
+	if denominator.Sign() == 0 {
+		return nil, errors.New("Ed25519 public key is the identity point")
+	}
+	denominator.ModInverse(denominator, curve25519_prime)
+	u := numerator.Mul(numerator, denominator)
+	u.Mod(u, curve25519_prime)
+	u_bytes := u.FillBytes(make([]byte, 32))
+	for i, j := 0, len(u_bytes)-1; i < j; i, j = i+1, j-1 {
+		u_bytes[i], u_bytes[j] = u_bytes[j], u_bytes[i]
+	}
+	return u_bytes, nil
+}
+
+// Returns the X25519 private key of an Ed25519 key pair, which is the
+// clamped first half of the SHA-512 hash of the seed.
+func X25519PrivateFromEd25519(private_key ed25519.PrivateKey) []byte {
+	digest := sha512.Sum512(private_key.Seed())
+	scalar := digest[:32]
+	scalar[0] &= 248
+	scalar[31] &= 127
+	scalar[31] |= 64
+	return scalar
+}
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestX25519FromEd25519(t *testing.T) {
+	pub_key, private_key, _ := ed25519.GenerateKey(rand.Reader)
+	converted, err := X25519PublicFromEd25519(pub_key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	derived, _ := curve25519.X25519(X25519PrivateFromEd25519(private_key), curve25519.Basepoint)
+	if !bytes.Equal(converted, derived) {
+		t.Error("the converted public key doesn't match the converted private key")
+	}
+}
+
+// Messages go both ways between Ed25519 and RSA identities.
+func TestMixedKeyTypes(t *testing.T) {
+	rsa_key, _ := GenerateKey(KEY_TYPE_RSA)
+	ed_key, _ := GenerateKey(KEY_TYPE_ED25519)
+	pairs := [][2]crypto.Signer{{ed_key, rsa_key}, {rsa_key, ed_key}, {ed_key, ed_key}}
+	for _, pair := range pairs {
+		sender, recipient := pair[0], pair[1]
+		message := Message{content: []byte("Hello"), public_key: EncodePublicKey(sender)}
+		message.Encrypt(recipient.Public())
+		message.Sign(NewKeyIdentity(sender))
+		parsed, err := MessageFromBytes(message.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := parsed.Verify(recipient.Public()); err != nil {
+			t.Fatalf("%T to %T: %v", sender, recipient, err)
+		}
+		if err := parsed.Decrypt(NewKeyIdentity(recipient)); err != nil || string(parsed.content) != "Hello" {
+			t.Fatalf("%T to %T: could not decrypt: %v", sender, recipient, err)
+		}
+	}
+	// an Ed25519 signature can't pass as an RSA one
+	message := Message{content: []byte("Hello"), public_key: EncodePublicKey(ed_key)}
+	message.Encrypt(rsa_key.Public())
+	message.Sign(NewKeyIdentity(ed_key))
+	message.signature_algorithm = PBSignatureAlgorithm_SIGNATURE_RSA_PSS
+	if message.VerifySignature() {
+		t.Error("signature verified with the wrong algorithm")
+	}
+}
+
+// A reader with an Ed25519 key and no prekeys can still read messages,
+// which are wrapped for its identity's X25519 key.
+func TestEd25519ReaderWithoutPrekeys(t *testing.T) {
+	var events bytes.Buffer
+	SetDisplay(NewJSONDisplay(&events, &MessangerConfig{GroupID: "friends"}))
+	defer SetDisplay(nil)
+	sender, _ := GenerateKey(KEY_TYPE_ED25519)
+	reader, _ := GenerateKey(KEY_TYPE_ED25519)
+	payload := Payload{message_id: "m1", timestamp: time.Now().UnixMilli(), group_id: "friends", text: "Hello"}
+	sealed, err := SealPayload(payload, NewKeyIdentity(sender), reader.Public(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webt := &WEBTransport{identity: NewKeyIdentity(reader), group_id: "friends"}
+	message, _ := MessageFromBytes(sealed)
+	if err := message.Verify(reader.Public()); err != nil {
+		t.Fatal(err)
+	}
+	if err := webt.decrypt(&message); err != nil {
+		t.Fatalf("could not decrypt without prekeys: %v", err)
+	}
+	friend_map := createFriendPubKeyMap([]FriendDetail{{public_key: sender.Public(), name: "Bill"}})
+	webt.handleMessage(sealed, "", friend_map)
+	if !strings.Contains(events.String(), `"text":"Hello"`) {
+		t.Errorf("the reader did not show the message: %q", events.String())
+	}
+}
+
+func TestEd25519KeyFile(t *testing.T) {
+	key, _ := GenerateKey(KEY_TYPE_ED25519)
+	key_file := filepath.Join(t.TempDir(), "id_ed25519")
+	WriteKeyToDisk(key, key_file, nil)
+	read := ReadExistingKey(key_file)
+	if !bytes.Equal(PublicKeyToBytes(read.Public()), PublicKeyToBytes(key.Public())) {
+		t.Error("the key read back doesn't match the key written")
+	}
+	pub_pem, _ := os.ReadFile(key_file + ".pub")
+	parsed, err := ParsePublicKey(pub_pem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key_type, _ := KeyType(parsed); key_type != KEY_TYPE_ED25519 {
+		t.Errorf("expected an ed25519 public key, got %v", key_type)
+	}
+}
+
+func TestAuthenticateEd25519Request(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	key, _ := GenerateKey(KEY_TYPE_ED25519)
+	body := []byte("hello")
+	req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewReader(body))
+	req.Header.Set(HEADER_TARGET_PUBLIC_KEY, "recipient")
+	SignRequest(req, NewKeyIdentity(key), body)
+	if code, reached := authenticate(cs, req); code != http.StatusOK || !reached {
+		t.Errorf("a request signed with ed25519 should be let through, got %v", code)
+	}
+}
+
+func TestEncryptedKeyFile(t *testing.T) {
+	for _, key_type := range []string{KEY_TYPE_RSA, KEY_TYPE_ED25519} {
+		key, _ := GenerateKey(key_type)
+		encrypted, err := MarshalEncryptedPrivateKey(key, []byte("hunter2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncryptedKey(encrypted) {
+			t.Fatalf("%v key was not encrypted", key_type)
+		}
+		attempts := 0
+		guesses := [][]byte{[]byte("wrong"), []byte("hunter2")}
+		read, err := ParsePrivateKey(encrypted, func() ([]byte, error) {
+			attempts++
+			return guesses[attempts-1], nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts != 2 || !bytes.Equal(PublicKeyToBytes(read.Public()), PublicKeyToBytes(key.Public())) {
+			t.Errorf("%v key was not decrypted after a wrong guess", key_type)
+		}
+		_, err = ParsePrivateKey(encrypted, func() ([]byte, error) { return []byte("wrong"), nil })
+		if err == nil {
+			t.Errorf("%v key was decrypted with the wrong passphrase", key_type)
+		}
+		// removing the passphrase gives a key that reads without one
+		plain, _ := MarshalEncryptedPrivateKey(read, nil)
+		if IsEncryptedKey(plain) {
+			t.Errorf("%v key is still encrypted without a passphrase", key_type)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Request size limits for the relay server.
+
+Every request body is read through http.MaxBytesReader, so no client
+can make the relay hold more than the limit for its endpoint:
+max_envelope_size for one message, max_batch_size for a batch or a
+websocket frame (which can carry one), and MAX_BLOB_CHUNK for a piece
+of a file. Anything bigger gets a 413 saying what the limit is.
+
+Blob chunks aren't held in memory at all. They are written to a
+temporary file in the blob directory as they are read and hashed,
+and handed to the blob store from there once the signature checks out.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	DEFAULT_MAX_ENVELOPE_SIZE = 1024 * 256
+	DEFAULT_MAX_BATCH_SIZE    = 1024 * 1024 * 4
+	// the most a client reads in one websocket frame
+	CLIENT_FRAME_LIMIT = 1024 * 1024 * 64
+	// sent when subscribing, so clients don't write frames the relay would refuse
+	HEADER_MAX_FRAME_SIZE = "MAX_FRAME_SIZE"
+)
+
+var errMessageTooLarge = errors.New("message is too large for the relay, send long text as a file with /file")
+
+// The most the relay reads of the request's body.
+func (cs *ChatServer) requestLimit(r *http.Request) int64 {
+	switch {
+	case r.URL.Path == "/publish/batch":
+		return cs.max_batch_size
+	case strings.HasPrefix(r.URL.Path, "/blobs/"):
+		return MAX_BLOB_CHUNK
+	}
+	return cs.max_envelope_size
+}
+
+// Whether the request's body is written to disk as it's read.
+func (cs *ChatServer) streamsBody(r *http.Request) bool {
+	return cs.blobs != nil && r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/blobs/")
+}
+
+// Reads the request body, up to its limit, and returns its SHA-256
+// hash. r.Body is left reading the same body from the start, and the
+// returned function frees whatever is holding it.
+func (cs *ChatServer) readRequestBody(w http.ResponseWriter, r *http.Request) ([]byte, func(), error) {
+	reader := http.MaxBytesReader(w, r.Body, cs.requestLimit(r))
+	defer reader.Close()
+	digest := sha256.New()
+	if !cs.streamsBody(r) {
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		digest.Write(body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return digest.Sum(nil), func() {}, nil
+	}
+	spool, err := os.CreateTemp(cs.blobs.dir, BLOB_SPOOL_PREFIX+"*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not spool request body... %w", err)
+	}
+	cleanup := func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}
+	_, err = io.Copy(io.MultiWriter(spool, digest), reader)
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	r.Body = spool
+	return digest.Sum(nil), cleanup, nil
+}
+
+// Responds to a body that couldn't be read, with a 413 if it was
+// over the limit.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var too_large *http.MaxBytesError
+	if errors.As(err, &too_large) {
+		http.Error(w, fmt.Sprintf("request is larger than the relay's limit of %v", formatSize(too_large.Limit)), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "failed to read request body", http.StatusInternalServerError)
+}
+
+// Tells subscribers the largest frame they may write.
+func (cs *ChatServer) advertiseLimits(w http.ResponseWriter) {
+	w.Header().Set(HEADER_MAX_FRAME_SIZE, strconv.FormatInt(cs.max_batch_size, 10))
+}
+
+// Remembers the largest frame the relay takes, from its answer to
+// our subscribe. Relays that don't say are assumed to take anything.
+func (webt *WEBTransport) recordLimits(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	limit, err := strconv.ParseInt(resp.Header.Get(HEADER_MAX_FRAME_SIZE), 10, 64)
+	if err != nil {
+		limit = 0
+	}
+	webt.frame_limit.Store(limit)
+}
+
+// Whether a frame of this size would be refused by the relay.
+func (webt *WEBTransport) frameTooLarge(size int) bool {
+	limit := webt.frame_limit.Load()
+	return limit > 0 && int64(size) > limit
+}
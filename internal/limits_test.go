@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Bodies over the limit are refused with a 413 that the client
+// turns into errMessageTooLarge.
+func TestRequestLimits(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	cs.max_envelope_size = 64
+	cs.max_batch_size = 4096
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	alice := &WEBTransport{host_url: server.URL, identity: NewKeyIdentity(GenerateRandomKey())}
+	bill := &FriendDetail{public_key: &GenerateRandomKey().PublicKey, name: "Bill"}
+	andy := &FriendDetail{public_key: &GenerateRandomKey().PublicKey, name: "Andy"}
+
+	if err := alice.Writer(bill, make([]byte, 64)); err != nil {
+		t.Errorf("expected a message at the limit to be published, got %v", err)
+	}
+	err := alice.Writer(bill, make([]byte, 65))
+	if !errors.Is(err, errMessageTooLarge) {
+		t.Errorf("expected a message over the limit to be refused, got %v", err)
+	}
+
+	// a batch under its limit still has each entry checked
+	errs := alice.BatchWriter([]OutboundMessage{
+		{friend: bill, content: make([]byte, 10)},
+		{friend: andy, content: make([]byte, 100)},
+	})
+	if errs[0] != nil || errs[1] == nil {
+		t.Errorf("expected only the oversized entry to fail, got %v", errs)
+	}
+	_, err = alice.postBatch(&PBBatchPublish{Entries: []*PBBatchEntry{{Payload: make([]byte, 5000)}}})
+	if !errors.Is(err, errMessageTooLarge) {
+		t.Errorf("expected a batch over the limit to be refused, got %v", err)
+	}
+}
+
+// Blob chunks are spooled to disk, and the spool is gone afterwards.
+func TestStreamedBlobChunk(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	dir := t.TempDir()
+	cs.blobs, _ = OpenBlobStore(dir, 0, 0)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	alice := &WEBTransport{host_url: server.URL, identity: NewKeyIdentity(GenerateRandomKey())}
+
+	id, err := alice.createBlob(MAX_BLOB_CHUNK * 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := bytes.Repeat([]byte("a"), MAX_BLOB_CHUNK)
+	if err := alice.putBlobChunk(id, 0, chunk); err != nil {
+		t.Fatal(err)
+	}
+	err = alice.putBlobChunk(id, MAX_BLOB_CHUNK, append(chunk, 'a'))
+	if err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("expected a chunk over the limit to be refused, got %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), BLOB_SPOOL_PREFIX) {
+			t.Errorf("spooled chunk %v was left behind", entry.Name())
+		}
+	}
+	if cs.requestLimit(httptest.NewRequest(http.MethodPut, "/blobs/"+id, nil)) != MAX_BLOB_CHUNK {
+		t.Error("expected blob chunks to get the chunk limit")
+	}
+}
@@ -0,0 +1,383 @@
+/*
+Store-and-forward mailboxes for the relay server.
+
+Every recipient public key gets a Mailbox holding the ciphertexts
+published to it. Envelopes stay in the mailbox until the recipient
+acks them or they outlive the configured TTL. Nothing already queued
+is pushed out to make room: once a mailbox holds its quota, new
+envelopes are refused, and the sender told the mailbox is full,
+until some are acked or expire.
+A subscriber that connects later is sent everything still waiting
+in its mailbox, which makes delivery at-least-once instead of
+fire-and-forget.
+
+One key can be read on several devices, each subscribing with its
+own device ID. Each device acks envelopes for itself, and an envelope
+is only removed once every device that reads the mailbox has acked
+it, so a device that is offline still gets it later. Devices that
+haven't been seen for longer than the TTL are forgotten, since
+anything they were waiting for has expired anyway.
+
+Anyone can publish to any valid key, so the relay caps how many
+mailboxes it holds, and compaction drops mailboxes that have nothing
+queued, no devices and nobody connected.
+
+The ChatServer only talks to a MailboxStorage. The in-memory store
+lives here, the durable one lives in storage.go.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_MAILBOX_TTL   = time.Hour * 72
+	DEFAULT_MAILBOX_QUOTA = 1024 * 1024 * 4
+	// the least recently seen device is forgotten to make room for more
+	MAX_MAILBOX_DEVICES = 32
+	// new keys are refused once the relay holds this many mailboxes
+	DEFAULT_MAX_MAILBOXES = 100000
+)
+
+// Where the relay keeps queued envelopes, delivery cursors and other per-key state.
+type MailboxStorage interface {
+	// Queues the payload for the given recipient and returns the envelope ID.
+	// Fails if the recipient's mailbox is full.
+	Enqueue(pub_key string, payload []byte) (uint64, error)
+	// Returns the unexpired envelopes with an ID greater than after_id
+	// that the device hasn't acked, and starts keeping envelopes for
+	// the device if it is new.
+	Pending(pub_key string, device_id string, after_id uint64) ([]Envelope, error)
+	// Records that the device has the envelope with the given ID. It is
+	// removed from the mailbox once every device has it.
+	Ack(pub_key string, device_id string, id uint64) error
+	// Drops expired envelopes and reclaims the space they used, along
+	// with mailboxes left empty whose key in_use says isn't connected.
+	Compact(in_use func(pub_key string) bool) error
+	Close() error
+}
+
+// A ciphertext waiting in a mailbox.
+// IDs are assigned in increasing order per mailbox, so a subscriber
+// only needs to remember the last ID it was sent.
+// A new mailbox starts counting from the clock, so that IDs keep
+// increasing even when a relay without storage restarts and a
+// reader resumes with an ID from before the restart.
+type Envelope struct {
+	id         uint64
+	payload    []byte
+	expires_at time.Time
+	// devices that have acked the envelope
+	acked_by map[string]bool
+}
+
+// The queue of envelopes for a single recipient public key.
+// size is the sum of the payload lengths and is checked against the quota.
+type Mailbox struct {
+	envelopes []Envelope
+	size      int
+	next_id   uint64
+	// every device reading the mailbox, by device ID
+	devices map[string]*Device
+}
+
+// A device reading a mailbox. What it has acked is kept on each envelope.
+type Device struct {
+	last_seen time.Time
+}
+
+// Keeps every mailbox in memory. Nothing survives a restart.
+type MemoryMailboxStore struct {
+	mutex         sync.Mutex
+	mailboxes     map[string]*Mailbox
+	ttl           time.Duration
+	quota         int
+	max_mailboxes int
+}
+
+func NewMemoryMailboxStore(ttl time.Duration, quota int) *MemoryMailboxStore {
+	if ttl <= 0 {
+		ttl = DEFAULT_MAILBOX_TTL
+	}
+	if quota <= 0 {
+		quota = DEFAULT_MAILBOX_QUOTA
+	}
+	return &MemoryMailboxStore{
+		mailboxes:     map[string]*Mailbox{},
+		ttl:           ttl,
+		quota:         quota,
+		max_mailboxes: DEFAULT_MAX_MAILBOXES,
+	}
+}
+
+// Keeps the default when max_mailboxes isn't set.
+func (store *MemoryMailboxStore) setMaxMailboxes(max_mailboxes int) {
+	if max_mailboxes > 0 {
+		store.max_mailboxes = max_mailboxes
+	}
+}
+
+// Returns the mailbox for the given key, creating it if needed.
+// Callers must hold the store mutex.
+func (store *MemoryMailboxStore) mailbox(pub_key string) *Mailbox {
+	box, ok := store.mailboxes[pub_key]
+	if !ok {
+		box = &Mailbox{next_id: uint64(time.Now().UnixMicro()), devices: map[string]*Device{}}
+		store.mailboxes[pub_key] = box
+	}
+	return box
+}
+
+// Like mailbox, but fails instead of creating a mailbox once the store
+// holds max_mailboxes. Used for anything a client can ask for, while
+// replaying the log is not limited.
+// Callers must hold the store mutex.
+func (store *MemoryMailboxStore) limitedMailbox(pub_key string) (*Mailbox, error) {
+	if _, ok := store.mailboxes[pub_key]; !ok && len(store.mailboxes) >= store.max_mailboxes {
+		return nil, fmt.Errorf("the relay is holding too many mailboxes (%v)", store.max_mailboxes)
+	}
+	return store.mailbox(pub_key), nil
+}
+
+func (store *MemoryMailboxStore) Enqueue(pub_key string, payload []byte) (uint64, error) {
+	envelope, err := store.enqueue(pub_key, payload)
+	return envelope.id, err
+}
+
+// Fails if the payload would push the mailbox over its quota.
+func (store *MemoryMailboxStore) enqueue(pub_key string, payload []byte) (Envelope, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	box, err := store.limitedMailbox(pub_key)
+	if err != nil {
+		return Envelope{}, err
+	}
+	box.dropExpired(time.Now(), store.ttl)
+	if box.size+len(payload) > store.quota {
+		return Envelope{}, fmt.Errorf("mailbox for recipient is full (%v of %v bytes used)", box.size, store.quota)
+	}
+	envelope := Envelope{
+		id:         box.next_id,
+		payload:    payload,
+		expires_at: time.Now().Add(store.ttl),
+	}
+	box.next_id++
+	box.envelopes = append(box.envelopes, envelope)
+	box.size += len(payload)
+	return envelope, nil
+}
+
+func (store *MemoryMailboxStore) Pending(pub_key string, device_id string, after_id uint64) ([]Envelope, error) {
+	pending, _, err := store.pending(pub_key, device_id, after_id)
+	return pending, err
+}
+
+// Also returns whether the device is new.
+func (store *MemoryMailboxStore) pending(pub_key string, device_id string, after_id uint64) ([]Envelope, bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	box, err := store.limitedMailbox(pub_key)
+	if err != nil {
+		return nil, false, err
+	}
+	box.dropExpired(time.Now(), store.ttl)
+	_, known := box.devices[device_id]
+	box.device(device_id, time.Now())
+	var pending []Envelope
+	for _, envelope := range box.envelopes {
+		if envelope.id > after_id && !envelope.acked_by[device_id] {
+			pending = append(pending, envelope)
+		}
+	}
+	return pending, !known, nil
+}
+
+// Acking an unknown ID is not an error, since delivery is at-least-once
+// and the same envelope may be acked more than once.
+func (store *MemoryMailboxStore) Ack(pub_key string, device_id string, id uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.ack(pub_key, device_id, id, time.Now())
+	return nil
+}
+
+// There is nothing to ack without a mailbox, so none is created.
+// Callers must hold the store mutex.
+func (store *MemoryMailboxStore) ack(pub_key string, device_id string, id uint64, now time.Time) {
+	box, ok := store.mailboxes[pub_key]
+	if !ok {
+		return
+	}
+	box.device(device_id, now)
+	for i := range box.envelopes {
+		if box.envelopes[i].id == id {
+			if box.envelopes[i].acked_by == nil {
+				box.envelopes[i].acked_by = map[string]bool{}
+			}
+			box.envelopes[i].acked_by[device_id] = true
+			break
+		}
+	}
+	box.dropDelivered()
+}
+
+// Takes back an envelope that was just queued, without it counting
+// as acked by anyone.
+func (store *MemoryMailboxStore) remove(pub_key string, id uint64) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	box, ok := store.mailboxes[pub_key]
+	if !ok {
+		return
+	}
+	for i, envelope := range box.envelopes {
+		if envelope.id == id {
+			box.size -= len(envelope.payload)
+			box.envelopes = append(box.envelopes[:i], box.envelopes[i+1:]...)
+			return
+		}
+	}
+}
+
+// A mailbox is only dropped once it has no envelopes and no devices,
+// so the relay keeps waiting for acks from a device that is offline.
+// A new mailbox for the key starts its IDs from the clock,
+// so they still go up.
+func (store *MemoryMailboxStore) Compact(in_use func(pub_key string) bool) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	now := time.Now()
+	for pub_key, box := range store.mailboxes {
+		box.dropExpired(now, store.ttl)
+		if len(box.envelopes) == 0 && len(box.devices) == 0 && (in_use == nil || !in_use(pub_key)) {
+			delete(store.mailboxes, pub_key)
+		}
+	}
+	return nil
+}
+
+func (store *MemoryMailboxStore) Close() error {
+	return nil
+}
+
+// Puts an envelope read back from disk into its mailbox.
+// Expired envelopes are skipped.
+func (store *MemoryMailboxStore) restore(pub_key string, envelope Envelope) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	box := store.mailbox(pub_key)
+	if envelope.id >= box.next_id {
+		box.next_id = envelope.id + 1
+	}
+	if time.Now().After(envelope.expires_at) {
+		return
+	}
+	box.envelopes = append(box.envelopes, envelope)
+	box.size += len(envelope.payload)
+}
+
+// Applies an ack read back from disk, as of when it was made.
+func (store *MemoryMailboxStore) restoreAck(pub_key string, device_id string, id uint64, acked_at time.Time) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.ack(pub_key, device_id, id, acked_at)
+}
+
+// Restores the per-key counters and devices read back from disk.
+func (store *MemoryMailboxStore) restoreState(pub_key string, next_id uint64, devices map[string]*Device) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	box := store.mailbox(pub_key)
+	if next_id > box.next_id {
+		box.next_id = next_id
+	}
+	for device_id, device := range devices {
+		box.devices[device_id] = device
+	}
+}
+
+// Returns the device with the given ID, adding it if it's new, and
+// marks it as seen. Adding a device past MAX_MAILBOX_DEVICES forgets
+// the one seen least recently.
+func (box *Mailbox) device(device_id string, now time.Time) *Device {
+	device, ok := box.devices[device_id]
+	if !ok {
+		if len(box.devices) >= MAX_MAILBOX_DEVICES {
+			box.forgetDevice(box.leastRecentDevice())
+		}
+		device = &Device{}
+		box.devices[device_id] = device
+	}
+	if now.After(device.last_seen) {
+		device.last_seen = now
+	}
+	return device
+}
+
+func (box *Mailbox) leastRecentDevice() string {
+	oldest := ""
+	var oldest_seen time.Time
+	for device_id, device := range box.devices {
+		if oldest_seen.IsZero() || device.last_seen.Before(oldest_seen) {
+			oldest, oldest_seen = device_id, device.last_seen
+		}
+	}
+	return oldest
+}
+
+func (box *Mailbox) forgetDevice(device_id string) {
+	delete(box.devices, device_id)
+	for _, envelope := range box.envelopes {
+		delete(envelope.acked_by, device_id)
+	}
+}
+
+// Removes envelopes that have outlived the TTL, forgets devices that
+// haven't been seen within it, and removes whatever every remaining
+// device has acked.
+func (box *Mailbox) dropExpired(now time.Time, ttl time.Duration) {
+	kept := box.envelopes[:0]
+	for _, envelope := range box.envelopes {
+		if now.After(envelope.expires_at) {
+			box.size -= len(envelope.payload)
+			continue
+		}
+		kept = append(kept, envelope)
+	}
+	box.envelopes = kept
+	for device_id, device := range box.devices {
+		if now.Sub(device.last_seen) > ttl {
+			box.forgetDevice(device_id)
+		}
+	}
+	box.dropDelivered()
+}
+
+// Removes the envelopes that every device has acked.
+func (box *Mailbox) dropDelivered() {
+	kept := box.envelopes[:0]
+	for _, envelope := range box.envelopes {
+		if box.deliveredEverywhere(envelope) {
+			box.size -= len(envelope.payload)
+			continue
+		}
+		kept = append(kept, envelope)
+	}
+	box.envelopes = kept
+}
+
+func (box *Mailbox) deliveredEverywhere(envelope Envelope) bool {
+	if len(box.devices) == 0 {
+		return false
+	}
+	for device_id := range box.devices {
+		if !envelope.acked_by[device_id] {
+			return false
+		}
+	}
+	return true
+}
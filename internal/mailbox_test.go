@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMailboxPendingAndAck(t *testing.T) {
+	store := NewMemoryMailboxStore(time.Hour, 1024)
+	first, _ := store.Enqueue("bob", []byte("one"))
+	second, _ := store.Enqueue("bob", []byte("two"))
+	if pending, _ := store.Pending("bob", "", 0); len(pending) != 2 {
+		t.Errorf("expected 2 pending envelopes, got %v", len(pending))
+	}
+	if pending, _ := store.Pending("bob", "", first); len(pending) != 1 || pending[0].id != second {
+		t.Errorf("expected only the second envelope after id %v, got %v", first, pending)
+	}
+	store.Ack("bob", "", first)
+	pending, _ := store.Pending("bob", "", 0)
+	if len(pending) != 1 || string(pending[0].payload) != "two" {
+		t.Errorf("unexpected pending envelopes after ack: %v", pending)
+	}
+	if pending, _ := store.Pending("alice", "", 0); len(pending) != 0 {
+		t.Errorf("alice should have an empty mailbox, got %v", pending)
+	}
+}
+
+func TestMailboxQuota(t *testing.T) {
+	store := NewMemoryMailboxStore(time.Hour, 10)
+	if _, err := store.Enqueue("bob", []byte("123456")); err != nil {
+		t.Error(err)
+	}
+	if _, err := store.Enqueue("bob", []byte("123456")); err == nil {
+		t.Error("expected the second envelope to exceed the quota")
+	}
+	pending, _ := store.Pending("bob", "", 0)
+	first := pending[0]
+	store.Ack("bob", "", first.id)
+	if _, err := store.Enqueue("bob", []byte("123456")); err != nil {
+		t.Errorf("acking should free up quota: %v", err)
+	}
+}
+
+func TestMailboxTTL(t *testing.T) {
+	store := NewMemoryMailboxStore(time.Millisecond, 1024)
+	store.Enqueue("bob", []byte("old news"))
+	time.Sleep(time.Millisecond * 5)
+	if pending, _ := store.Pending("bob", "", 0); len(pending) != 0 {
+		t.Errorf("expired envelopes should be dropped, got %v", pending)
+	}
+}
+
+// Envelopes wait for every device, except those not seen within the TTL.
+func TestMailboxDevices(t *testing.T) {
+	store := NewMemoryMailboxStore(time.Millisecond*50, 1024)
+	store.Pending("bob", "laptop", 0)
+	store.Pending("bob", "phone", 0)
+	id, _ := store.Enqueue("bob", []byte("one"))
+	store.Ack("bob", "laptop", id)
+	if pending, _ := store.Pending("bob", "laptop", 0); len(pending) != 0 {
+		t.Errorf("the laptop was sent an envelope it acked: %v", pending)
+	}
+	if pending, _ := store.Pending("bob", "phone", 0); len(pending) != 1 {
+		t.Fatalf("the phone should still have the envelope, got %v", pending)
+	}
+	store.Ack("bob", "phone", id)
+	if len(store.mailboxes["bob"].envelopes) != 0 {
+		t.Error("an envelope every device acked is still queued")
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	store.Pending("bob", "laptop", 0)
+	id, _ = store.Enqueue("bob", []byte("two"))
+	store.Ack("bob", "laptop", id)
+	if len(store.mailboxes["bob"].envelopes) != 0 {
+		t.Error("an envelope is waiting for a device that was forgotten")
+	}
+}
+
+// New keys are refused once the store holds max_mailboxes, while keys
+// that already have a mailbox can still be published to.
+func TestMailboxLimit(t *testing.T) {
+	store := NewMemoryMailboxStore(time.Hour, 1024)
+	store.setMaxMailboxes(1)
+	if _, err := store.Enqueue("bob", []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Enqueue("carol", []byte("one")); err == nil {
+		t.Error("expected a second mailbox to go over the limit")
+	}
+	if _, err := store.Pending("carol", "", 0); err == nil {
+		t.Error("subscribing shouldn't make a mailbox over the limit either")
+	}
+	if _, err := store.Enqueue("bob", []byte("two")); err != nil {
+		t.Errorf("bob's mailbox already exists: %v", err)
+	}
+}
+
+// Compacting drops mailboxes with nothing queued, no devices and
+// nobody connected.
+func TestCompactDropsEmptyMailboxes(t *testing.T) {
+	store := NewMemoryMailboxStore(time.Millisecond*50, 1024)
+	store.Enqueue("stranger", []byte("one"))
+	store.Enqueue("connected", []byte("one"))
+	store.Pending("reader", "laptop", 0)
+	time.Sleep(time.Millisecond * 60)
+	store.Pending("recent", "laptop", 0)
+	store.Compact(func(pub_key string) bool { return pub_key == "connected" })
+	for _, pub_key := range []string{"stranger", "reader"} {
+		if _, ok := store.mailboxes[pub_key]; ok {
+			t.Errorf("the empty mailbox for %v wasn't dropped", pub_key)
+		}
+	}
+	for _, pub_key := range []string{"connected", "recent"} {
+		if _, ok := store.mailboxes[pub_key]; !ok {
+			t.Errorf("the mailbox for %v was dropped", pub_key)
+		}
+	}
+}
@@ -9,7 +9,10 @@ package internal
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"time"
 
 	"golang.org/x/term"
 )
@@ -17,21 +20,94 @@ import (
 const MAX_MESSAGE_WIDTH = 55
 const MAX_TOTAL_WIDTH = 58
 
+// Where messages are printed. The chat command points this at
+// readline so incoming messages don't clobber the prompt.
+var output io.Writer = os.Stdout
+
+func SetOutput(writer io.Writer) {
+	output = writer
+}
+
+// Shows what the reader receives instead of printing it,
+// for a full-screen UI like the one in tui.go.
+type MessageDisplay interface {
+	ShowMessage(message DisplayedMessage)
+	ShowConnectionStatus(status string)
+	// summary has each recipient's status, see DeliveryTracker
+	ShowDeliveryStatus(message_id string, summary string)
+}
+
+// A message as the reader would print it.
+type DisplayedMessage struct {
+	message_id string
+	group_id   string
+	sender     string
+	from_self  bool
+	// the sender's key fingerprint, and whether it was checked with 'peppermint verify'
+	fingerprint string
+	verified    bool
+	// unix milliseconds
+	timestamp int64
+	text      string
+}
+
+// The sender's name, with whether they've been verified the way
+// the reader prints it.
+func (message DisplayedMessage) senderLabel() string {
+	if message.from_self {
+		return message.sender
+	}
+	friend := FriendDetail{name: message.sender, verified: message.verified}
+	return friend.displayName()
+}
+
+// The kinds of problem a reader can run into.
+const (
+	READ_ERROR_CONNECTION     = "connection"
+	READ_ERROR_MALFORMED      = "malformed"
+	READ_ERROR_REJECTED       = "rejected"
+	READ_ERROR_DECRYPT        = "decrypt"
+	READ_ERROR_UNKNOWN_SENDER = "unknown_sender"
+)
+
+// Something that went wrong reading a message, or the connection.
+type DisplayedError struct {
+	kind string
+	text string
+	// who the message claimed to be from, when that is known
+	sender      string
+	fingerprint string
+	group_id    string
+}
+
+// Implemented by displays that want problems as events of their own,
+// rather than as lines written to output.
+type ErrorDisplay interface {
+	ShowError(problem DisplayedError)
+}
+
+// nil means messages are printed to output
+var display MessageDisplay
+
+func SetDisplay(new_display MessageDisplay) {
+	display = new_display
+}
+
 func PrintRightJustifiedMessage(message string) {
 	cols, _, err := term.GetSize(0)
 	if err != nil {
 		panic(err)
 	}
 	border_string := rightJustifyText(cols, strings.Repeat("-", MAX_TOTAL_WIDTH-1))
-	fmt.Println(border_string)
+	fmt.Fprintln(output, border_string)
 	message_parts := strings.Split(message, "\n")
 	for _, message_part := range message_parts {
 		message_chunks := batchMessage(message_part, MAX_MESSAGE_WIDTH)
 		for _, chunk := range message_chunks {
-			fmt.Println(rightJustifyText(cols, chunk))
+			fmt.Fprintln(output, rightJustifyText(cols, chunk))
 		}
 	}
-	fmt.Println(border_string + "\n")
+	fmt.Fprintln(output, border_string+"\n")
 }
 
 // Chops up the given message into chunks of size MAX_MESSAGE_WIDTH
@@ -42,13 +118,28 @@ func PrintLeftJustifiedMessage(message string) {
 		panic(err)
 	}
 	border_string := strings.Repeat("-", MAX_TOTAL_WIDTH) + "|"
-	fmt.Println(border_string)
+	fmt.Fprintln(output, border_string)
 	for _, message_part := range strings.Split(message, "\n") {
 		for _, chunk := range batchMessage(message_part, MAX_MESSAGE_WIDTH) {
-			fmt.Println(leftJustifyText(cols, chunk))
+			fmt.Fprintln(output, leftJustifyText(cols, chunk))
 		}
 	}
-	fmt.Println(border_string)
+	fmt.Fprintln(output, border_string)
+}
+
+// The line shown above a message: who sent it and when, and the
+// group it was sent to when that isn't the group being read.
+func messageHeader(name string, timestamp int64, group_label string) string {
+	sent_at := time.UnixMilli(timestamp)
+	layout := "Jan 2 15:04"
+	if sent_at.Format("2006-01-02") == time.Now().Format("2006-01-02") {
+		layout = "15:04"
+	}
+	header := fmt.Sprintf("%v - %v", name, sent_at.Format(layout))
+	if group_label != "" {
+		header += fmt.Sprintf(" - in %v", group_label)
+	}
+	return header
 }
 
 // Batches a message into chunks such that each chunk has length
@@ -86,3 +177,34 @@ func leftJustifyText(cols int, text string) string {
 	padding := strings.Repeat(" ", MAX_TOTAL_WIDTH-len(text))
 	return fmt.Sprintf("%s%s|", text, padding)
 }
+
+// Lets the user know what is going on with the connection to the server.
+func PrintConnectionStatus(status string) {
+	if display != nil {
+		display.ShowConnectionStatus(status)
+		return
+	}
+	fmt.Fprintf(output, "~ %v ~\n", status)
+}
+
+// Lets the user know the connection to the server failed.
+func PrintConnectionError(err error) {
+	if error_display, ok := display.(ErrorDisplay); ok {
+		error_display.ShowError(DisplayedError{kind: READ_ERROR_CONNECTION, text: err.Error()})
+		return
+	}
+	PrintConnectionStatus(err.Error())
+}
+
+// Tells the user about a message that couldn't be read.
+func printReadError(problem DisplayedError) {
+	if error_display, ok := display.(ErrorDisplay); ok {
+		error_display.ShowError(problem)
+		return
+	}
+	if problem.kind == READ_ERROR_REJECTED {
+		fmt.Fprintln(output, X_MARK, problem.text)
+		return
+	}
+	fmt.Fprintln(output, problem.text)
+}
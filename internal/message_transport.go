@@ -10,16 +10,21 @@ package internal
 import (
 	"bytes"
 	"context"
-	"crypto/rsa"
+	"crypto"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/chzyer/readline"
+	"google.golang.org/protobuf/proto"
 	"nhooyr.io/websocket"
 )
 
@@ -35,19 +40,96 @@ type MessageTransport interface {
 	Reader()
 }
 
+// Implemented by transports that can send to many recipients at once.
+// Returns one error per outbound message, nil if it was sent.
+type BatchMessageTransport interface {
+	BatchWriter([]OutboundMessage) []error
+}
+
+// Implemented by transports that can have the server copy one
+// message to many recipients, so it is only sent once.
+// Returns one error per friend, nil if it was sent.
+type FanoutMessageTransport interface {
+	FanoutWriter(friends []*FriendDetail, content []byte) []error
+}
+
+// A serialized message along with who it is for.
+type OutboundMessage struct {
+	friend  *FriendDetail
+	content []byte
+}
+
 type Messanger struct {
 	recipients  []FriendDetail
 	wait_group  *sync.WaitGroup
-	private_key *rsa.PrivateKey
+	identity    Identity
 	port        string
 	transport   MessageTransport
 	write_mutex *sync.Mutex
+	group_id    string
+	tracker     *DeliveryTracker
+	// sequence number of the last message sent
+	sequence uint64
+	// where uploads in progress are recorded
+	files_dir string
+	// only failures are printed, for 'peppermint send --quiet'
+	quiet bool
+	// how many recipients messages couldn't be sent to, guarded by write_mutex
+	failed_sends int
+	// recipients already warned about having no prekeys, guarded by write_mutex
+	warned_no_prekeys map[string]bool
 }
 
 type WEBTransport struct {
-	friends     []FriendDetail
-	host_url    string
-	private_key *rsa.PrivateKey
+	friends  []FriendDetail
+	host_url string
+	identity Identity
+	// messages for other groups are labelled with the group's name
+	group_id     string
+	other_groups map[string]GroupDetail
+	// IDs of the messages the reader has shown
+	seen_messages *seenMessages
+	// receipts for the messages we sent are passed to the tracker
+	tracker *DeliveryTracker
+	// send a receipt when a message is shown, not just when it's decrypted
+	read_receipts bool
+	// only handle and ack receipts, leaving messages for another reader
+	receipts_only bool
+	// where our prekeys are kept, see prekeys.go
+	prekey_file  string
+	prekey_store *PrekeyStore
+	prekey_cache map[string]cachedPrekeys
+	prekey_mutex sync.Mutex
+	// people who join with our invites, and friends' new keys, go in this config file
+	config_file string
+	// ID of the last envelope the reader handled
+	last_message_id uint64
+	// sent when subscribing, so acks only count for this device
+	device_id string
+	// set when another transport shares the reader's connection
+	session sessionObserver
+	// nil means http.DefaultClient
+	http_client *http.Client
+	// kept with files offered to us, to fetch them later
+	tls_fingerprint string
+	files_dir       string
+	// the largest websocket frame the relay takes, 0 if it didn't say
+	frame_limit atomic.Int64
+}
+
+func (webt *WEBTransport) client() *http.Client {
+	if webt.http_client == nil {
+		return http.DefaultClient
+	}
+	return webt.http_client
+}
+
+// Lets a transport built on top of WEBTransport use the
+// websocket connection that the Reader keeps open.
+type sessionObserver interface {
+	connected(*websocket.Conn)
+	disconnected()
+	publishResult(*PBBatchResult)
 }
 
 // Publish the message to the WEB recips
@@ -58,12 +140,9 @@ func (webt *WEBTransport) Writer(friend *FriendDetail, content []byte) error {
 	if err != nil {
 		return fmt.Errorf("problem constructing publish request... %w", err)
 	}
-	sig, token := CreateSignature(webt.private_key)
 	req.Header.Set(HEADER_TARGET_PUBLIC_KEY, PublicKeyToString(friend.public_key))
-	req.Header.Set(HEADER_SIGNATURE_TOKEN, token)
-	req.Header.Set(HEADER_SIGNATURE_VALUE, sig)
-	req.Header.Set(HEADER_PUBLIC_KEY, PublicKeyToString(&webt.private_key.PublicKey))
-	resp, err := http.DefaultClient.Do(req)
+	SignRequest(req, webt.identity, content)
+	resp, err := webt.client().Do(req)
 	if err != nil {
 		return fmt.Errorf("problem performing publish request... %w", err)
 	}
@@ -72,84 +151,471 @@ func (webt *WEBTransport) Writer(friend *FriendDetail, content []byte) error {
 	}
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusRequestEntityTooLarge {
+		return fmt.Errorf("%w... %s", errMessageTooLarge, strings.TrimSpace(string(body)))
+	}
 	return fmt.Errorf("unable to publish message to server... %s", string(body))
 }
 
-// Read incoming messages from the websocket connection
+// Publish a message to many recipients with a single signed request
+// to /publish/batch. Falls back to a request per recipient if the
+// server doesn't know about batches.
+func (webt *WEBTransport) BatchWriter(batch []OutboundMessage) []error {
+	errs := make([]error, len(batch))
+	request := newBatchRequest(batch)
+	result, err := webt.postBatch(request)
+	if errors.Is(err, errBatchNotSupported) {
+		for i, outbound := range batch {
+			errs[i] = webt.Writer(outbound.friend, outbound.content)
+		}
+		return errs
+	}
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	return batchErrors(request, result)
+}
+
+// Builds the batch request for the given outbound messages.
+func newBatchRequest(batch []OutboundMessage) *PBBatchPublish {
+	request := &PBBatchPublish{}
+	for _, outbound := range batch {
+		request.Entries = append(request.Entries, &PBBatchEntry{
+			TargetKey: PublicKeyToString(outbound.friend.public_key),
+			Payload:   outbound.content,
+		})
+	}
+	return request
+}
+
+// Sends the same message to every friend with a single batch entry
+// for the server to fan out. A server from before fan-out ignores the
+// target keys and reports on the entry's empty target key alone, so
+// then the message is sent again with an entry per friend.
+func fanout(publish func(*PBBatchPublish) (*PBBatchResult, error), batch_writer func([]OutboundMessage) []error, friends []*FriendDetail, content []byte) []error {
+	entry := &PBBatchEntry{Payload: content}
+	for _, friend := range friends {
+		entry.TargetKeys = append(entry.TargetKeys, PublicKeyToString(friend.public_key))
+	}
+	result, err := publish(&PBBatchPublish{Entries: []*PBBatchEntry{entry}})
+	statuses := result.GetStatuses()
+	if errors.Is(err, errBatchNotSupported) || (err == nil && len(statuses) == 1 && statuses[0].GetTargetKey() == "") {
+		batch := make([]OutboundMessage, len(friends))
+		for i, friend := range friends {
+			batch[i] = OutboundMessage{friend: friend, content: content}
+		}
+		return batch_writer(batch)
+	}
+	if err != nil {
+		errs := make([]error, len(friends))
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	return statusErrors(entry.GetTargetKeys(), result)
+}
+
+func (webt *WEBTransport) FanoutWriter(friends []*FriendDetail, content []byte) []error {
+	return fanout(webt.postBatch, webt.BatchWriter, friends, content)
+}
+
+// Matches the statuses in the result up with the entries in the request.
+func batchErrors(request *PBBatchPublish, result *PBBatchResult) []error {
+	target_keys := make([]string, len(request.GetEntries()))
+	for i, entry := range request.GetEntries() {
+		target_keys[i] = entry.GetTargetKey()
+	}
+	return statusErrors(target_keys, result)
+}
+
+// Matches the statuses in the result up with the target keys.
+func statusErrors(target_keys []string, result *PBBatchResult) []error {
+	errs := make([]error, len(target_keys))
+	statuses := make(map[string]*PBDeliveryStatus, len(result.GetStatuses()))
+	for _, status := range result.GetStatuses() {
+		statuses[status.GetTargetKey()] = status
+	}
+	for i, target_key := range target_keys {
+		status, ok := statuses[target_key]
+		if !ok {
+			errs[i] = fmt.Errorf("server did not report a status for this recipient")
+		} else if !status.GetQueued() {
+			errs[i] = fmt.Errorf("%s", status.GetError())
+		}
+	}
+	return errs
+}
+
+var errBatchNotSupported = errors.New("server does not support batch publishing")
+
+func (webt *WEBTransport) postBatch(batch *PBBatchPublish) (*PBBatchResult, error) {
+	body, err := proto.Marshal(batch)
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize batch... %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webt.host_url+"/publish/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("problem constructing publish request... %w", err)
+	}
+	SignRequest(req, webt.identity, body)
+	resp, err := webt.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("problem performing publish request... %w", err)
+	}
+	defer resp.Body.Close()
+	resp_body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errBatchNotSupported
+	}
+	if resp.StatusCode == http.StatusRequestEntityTooLarge {
+		return nil, fmt.Errorf("%w... %s", errMessageTooLarge, strings.TrimSpace(string(resp_body)))
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unable to publish message to server... %s", string(resp_body))
+	}
+	result := &PBBatchResult{}
+	err = proto.Unmarshal(resp_body, result)
+	if err != nil {
+		return nil, fmt.Errorf("could not deserialize batch result... %w", err)
+	}
+	return result, nil
+}
+
+// Read incoming messages from the websocket connection.
+// Each envelope is acked once it has been handled so that the
+// server can drop it from our mailbox.
+// When the connection drops we reconnect with backoff, and resume
+// after the last envelope we saw.
 func (webt *WEBTransport) Reader() {
-	self_public_key := PublicKeyToString(&webt.private_key.PublicKey)
+	self_public_key := PublicKeyToString(webt.identity.Public())
 	friend_map := createFriendPubKeyMap(webt.friends)
-	headers := GenerateRequestAuthHeaders(webt.private_key)
-	options := websocket.DialOptions{HTTPHeader: *headers}
-	// ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	backoff := NewBackoff(RECONNECT_MIN_DELAY, RECONNECT_MAX_DELAY)
+	if !webt.receipts_only {
+		go webt.prekeyLoop()
+	}
+	for {
+		err := webt.readSession(self_public_key, friend_map, backoff)
+		delay := backoff.Next()
+		PrintConnectionError(err)
+		PrintConnectionStatus(fmt.Sprintf("reconnecting in %v\u2026", delay.Round(time.Millisecond*100)))
+		time.Sleep(delay)
+	}
+}
+
+// Dials the server with fresh auth headers and handles envelopes
+// until the connection fails.
+func (webt *WEBTransport) readSession(self_public_key string, friend_map FriendDetailMap, backoff *Backoff) error {
+	subscribe_url := webt.host_url + "/subscribe"
+	headers := GenerateRequestAuthHeaders(webt.identity, http.MethodGet, urlPath(subscribe_url), "", nil)
+	if webt.last_message_id > 0 && !webt.receipts_only {
+		headers.Set(HEADER_RESUME_AFTER, strconv.FormatUint(webt.last_message_id, 10))
+	}
+	if webt.device_id != "" {
+		headers.Set(HEADER_DEVICE_ID, webt.device_id)
+	}
+	options := websocket.DialOptions{HTTPHeader: *headers, HTTPClient: webt.client()}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	connection, _, err := websocket.Dial(ctx, webt.host_url+"/subscribe", &options)
+	connection, resp, err := websocket.Dial(ctx, subscribe_url, &options)
 	if err != nil {
-		err = fmt.Errorf("could not create websocket connection to host: %v, %w", webt.host_url, err)
-		fmt.Println(err)
-		os.Exit(1)
+		return fmt.Errorf("could not create websocket connection to host: %v, %w", webt.host_url, err)
+	}
+	defer connection.Close(websocket.StatusNormalClosure, "")
+	connection.SetReadLimit(CLIENT_FRAME_LIMIT)
+	webt.recordLimits(resp)
+	PrintConnectionStatus("connected to " + webt.host_url)
+	backoff.Reset()
+	if !webt.receipts_only {
+		go webt.refreshPrekey()
+	}
+	if webt.session != nil {
+		webt.session.connected(connection)
+		defer webt.session.disconnected()
 	}
 	for {
-		select {
-		case <-ctx.Done():
-			fmt.Println("Context cancelled by server... Exiting read loop")
-			return
-		default:
-			message_type, message_bytes, err := connection.Read(ctx)
-			if err != nil {
-				fmt.Println("error: could not read message from websocket conn: ", err)
-				return
-			}
-			if message_type != websocket.MessageBinary {
-				fmt.Println("error: could not read message of type: ", message_type.String())
-				continue
-			}
-			message, err := MessageFromBytes(message_bytes)
-			if err != nil {
-				fmt.Println("could not deserialize message...", err)
-				continue
-			}
-			err = message.Decrypt(webt.private_key)
-			if err != nil {
-				fmt.Println("Could not decrypt message: ", err)
-				continue
-			}
-			// if !message.VerifySignature() {
-			// 	fmt.Println("Could not verify signature of message. Skipping...")
-			// 	continue
-			// }
-			pub_key, err := ParsePublicKey(message.public_key)
-			if err != nil {
-				fmt.Println("Could not parse public key: ", err)
-				continue
-			}
-			pub_key_string := PublicKeyToString(pub_key)
-			// this message came from yourself, so print it right justified
-			if self_public_key == pub_key_string {
-				PrintRightJustifiedMessage(string(message.content))
-				fmt.Println()
-				continue
-			}
-			friend, ok := friend_map[pub_key_string]
-			if !ok {
-				fmt.Println("Could not find friend associated with public key: ", pub_key_string)
+		message_type, frame_bytes, err := connection.Read(ctx)
+		if err != nil {
+			return fmt.Errorf("could not read message from websocket conn: %w", err)
+		}
+		if message_type != websocket.MessageBinary {
+			printReadError(DisplayedError{kind: READ_ERROR_MALFORMED, text: "could not read message of type: " + message_type.String()})
+			continue
+		}
+		frame := &PBServerFrame{}
+		err = proto.Unmarshal(frame_bytes, frame)
+		if err != nil {
+			printReadError(DisplayedError{kind: READ_ERROR_MALFORMED, text: fmt.Sprint("could not deserialize frame... ", err)})
+			continue
+		}
+		if result := frame.GetPublishResult(); result != nil && webt.session != nil {
+			webt.session.publishResult(result)
+			continue
+		}
+		envelope := frame.GetEnvelope()
+		if envelope == nil {
+			continue
+		}
+		// the server may send an envelope again if our ack was lost
+		if envelope.GetId() > webt.last_message_id {
+			handled := webt.handleMessage(envelope.GetPayload(), self_public_key, friend_map)
+			webt.last_message_id = envelope.GetId()
+			if !handled {
 				continue
 			}
-			PrintLeftJustifiedMessage(friend.name)
-			PrintLeftJustifiedMessage(string(message.content))
-			fmt.Println()
+		} else if webt.receipts_only {
+			continue
+		}
+		err = sendAck(ctx, connection, envelope.GetId())
+		if err != nil {
+			return fmt.Errorf("could not ack message: %w", err)
 		}
 	}
+}
 
+// Tells the server that the envelope with the given ID was received.
+func sendAck(ctx context.Context, connection *websocket.Conn, id uint64) error {
+	frame := &PBClientFrame{
+		Frame: &PBClientFrame_Ack{Ack: &PBAck{Id: id}},
+	}
+	data, err := proto.Marshal(frame)
+	if err != nil {
+		return fmt.Errorf("could not serialize ack... %w", err)
+	}
+	return connection.Write(ctx, websocket.MessageBinary, data)
+}
+
+// Verifies a serialized Message, decrypts it and prints it under
+// the group it was sent to. Messages that fail verification are
+// dropped with a warning, and messages already shown are skipped.
+// Returns whether the envelope should be acked, which is always
+// unless the reader only handles receipts and this isn't one.
+func (webt *WEBTransport) handleMessage(message_bytes []byte, self_public_key string, friend_map FriendDetailMap) bool {
+	if webt.receipts_only {
+		return webt.handleReceipt(message_bytes)
+	}
+	message, err := MessageFromBytes(message_bytes)
+	if err != nil {
+		printReadError(DisplayedError{kind: READ_ERROR_MALFORMED, text: fmt.Sprint("could not deserialize message... ", err)})
+		return true
+	}
+	pub_key, err := ParsePublicKey(message.public_key)
+	if err != nil {
+		printReadError(DisplayedError{kind: READ_ERROR_MALFORMED, text: fmt.Sprint("Could not parse public key: ", err), group_id: message.group_id})
+		return true
+	}
+	pub_key_string := PublicKeyToString(pub_key)
+	// what a problem with the message says about where it came from
+	problem := DisplayedError{fingerprint: KeyFingerprint(pub_key), group_id: message.group_id}
+	// the mailbox is shared by every group, so the message may be for another one
+	group_label := ""
+	senders := friend_map
+	if message.group_id != webt.group_id {
+		if group, ok := webt.other_groups[message.group_id]; ok {
+			group_label = group.name
+			senders = group.friends
+		} else {
+			group_label = fmt.Sprintf("unknown group %q", message.group_id)
+		}
+	}
+	err = message.Verify(webt.identity.Public())
+	if err != nil {
+		sender := "an unknown key"
+		if friend, ok := senders[pub_key_string]; ok {
+			sender = friend.name
+			problem.sender = friend.name
+		}
+		problem.kind = READ_ERROR_REJECTED
+		problem.text = fmt.Sprintf("Rejected a message claiming to be from %v: %v", sender, err)
+		printReadError(problem)
+		return true
+	}
+	if friend, ok := senders[pub_key_string]; ok {
+		problem.sender = friend.name
+	}
+	err = webt.decrypt(&message)
+	if err != nil {
+		problem.kind = READ_ERROR_DECRYPT
+		problem.text = fmt.Sprint("Could not decrypt message: ", err)
+		printReadError(problem)
+		return true
+	}
+	payload, err := PayloadFromBytes(message.content)
+	if err != nil {
+		problem.kind = READ_ERROR_MALFORMED
+		problem.text = fmt.Sprint("could not deserialize message payload... ", err)
+		printReadError(problem)
+		return true
+	}
+	if !payload.MatchesEnvelope(&message) {
+		problem.kind = READ_ERROR_REJECTED
+		problem.text = "Rejected a message whose contents don't match its signed envelope"
+		printReadError(problem)
+		return true
+	}
+	if payload.introduction != nil {
+		webt.handleIntroduction(payload.introduction, pub_key, friend_map)
+		return true
+	}
+	if payload.rotation != nil {
+		webt.handleRotation(payload.rotation, pub_key, friend_map)
+		return true
+	}
+	if payload.receipt != nil {
+		if webt.tracker != nil && pub_key_string != self_public_key {
+			webt.tracker.Update(payload.receipt.message_id, pub_key_string, statusFromReceipt(payload.receipt.status))
+		}
+		return true
+	}
+	if webt.seen_messages == nil {
+		webt.seen_messages = newSeenMessages(SEEN_MESSAGE_LIMIT)
+	}
+	if !webt.seen_messages.Add(payload.message_id) {
+		return true
+	}
+	if CheckDebug() {
+		slog.Debug(
+			"Received message",
+			"message_id", payload.message_id,
+			"sequence", payload.sequence,
+			"group_id", payload.group_id,
+		)
+	}
+	if message.FromTheFuture() {
+		fmt.Fprintf(output, "%v The next message is dated %v, check the sender's clock\n", X_MARK, time.UnixMilli(message.timestamp).Format("2006-01-02 15:04:05"))
+	}
+	displayed := DisplayedMessage{
+		message_id:  payload.message_id,
+		group_id:    payload.group_id,
+		fingerprint: problem.fingerprint,
+		timestamp:   payload.timestamp,
+		text:        payload.text,
+	}
+	if self_public_key == pub_key_string && display != nil {
+		displayed.sender = "You"
+		displayed.from_self = true
+		// there's nobody to verify your own key with
+		displayed.verified = true
+		display.ShowMessage(displayed)
+		return true
+	}
+	// this message came from yourself, so print it right justified
+	if self_public_key == pub_key_string {
+		PrintRightJustifiedMessage(messageHeader("You", payload.timestamp, group_label))
+		PrintRightJustifiedMessage(payload.text)
+		fmt.Fprintln(output)
+		return true
+	}
+	friend, ok := senders[pub_key_string]
+	if !ok {
+		problem.kind = READ_ERROR_UNKNOWN_SENDER
+		problem.text = "Could not find friend associated with public key: " + pub_key_string
+		printReadError(problem)
+		return true
+	}
+	go webt.sendReceipt(friend, payload.group_id, payload.message_id, PBReceiptStatus_RECEIPT_DELIVERED)
+	text := payload.text
+	if payload.file != nil {
+		text = webt.receiveFile(payload.file, friend.name, text)
+	}
+	if display != nil {
+		displayed.sender = friend.name
+		displayed.verified = friend.verified
+		displayed.text = text
+		display.ShowMessage(displayed)
+	} else {
+		PrintLeftJustifiedMessage(messageHeader(friend.displayName(), payload.timestamp, group_label))
+		PrintLeftJustifiedMessage(text)
+		fmt.Fprintln(output)
+	}
+	if webt.read_receipts {
+		go webt.sendReceipt(friend, payload.group_id, payload.message_id, PBReceiptStatus_RECEIPT_READ)
+	}
+	return true
+}
+
+// Handles the envelope only if it holds a receipt from a friend,
+// for a writer that is leaving everything else to a reader.
+func (webt *WEBTransport) handleReceipt(message_bytes []byte) bool {
+	message, err := MessageFromBytes(message_bytes)
+	if err != nil || message.Verify(webt.identity.Public()) != nil {
+		return false
+	}
+	pub_key, err := ParsePublicKey(message.public_key)
+	if err != nil {
+		return false
+	}
+	if webt.decrypt(&message) != nil {
+		return false
+	}
+	payload, err := PayloadFromBytes(message.content)
+	if err != nil || payload.receipt == nil || !payload.MatchesEnvelope(&message) {
+		return false
+	}
+	if webt.tracker != nil {
+		webt.tracker.Update(payload.receipt.message_id, PublicKeyToString(pub_key), statusFromReceipt(payload.receipt.status))
+	}
+	return true
+}
+
+// How many message IDs a reader remembers to skip duplicates.
+const SEEN_MESSAGE_LIMIT = 1000
+
+// Remembers the IDs of the most recent messages, forgetting the
+// oldest once there are more than the limit.
+type seenMessages struct {
+	ids   map[string]bool
+	order []string
+	limit int
+}
+
+func newSeenMessages(limit int) *seenMessages {
+	return &seenMessages{ids: map[string]bool{}, limit: limit}
+}
+
+// Records the ID. Returns false if it was already recorded.
+func (seen *seenMessages) Add(id string) bool {
+	if seen.ids[id] {
+		return false
+	}
+	seen.ids[id] = true
+	seen.order = append(seen.order, id)
+	if len(seen.order) > seen.limit {
+		delete(seen.ids, seen.order[0])
+		seen.order = seen.order[1:]
+	}
+	return true
+}
+
+// A group from the config other than the one being read,
+// so messages sent to it can still be shown.
+type GroupDetail struct {
+	name    string
+	friends FriendDetailMap
 }
 
 // Holds details about who you will be sending/receiving messages from.
 type FriendDetail struct {
-	public_key       *rsa.PublicKey
+	public_key       crypto.PublicKey
 	message_channel  chan Message
 	inbound_messages chan []byte
 	name             string
+	// whether the key was checked with 'peppermint verify'
+	verified bool
+}
+
+// The friend's name with whether their key has been verified,
+// for the line above their messages.
+func (friend *FriendDetail) displayName() string {
+	if friend.verified {
+		return friend.name + " (verified)"
+	}
+	return friend.name + " (unverified)"
 }
 
 type FriendDetailMap map[string]FriendDetail
@@ -165,20 +631,102 @@ func createFriendPubKeyMap(friend_list []FriendDetail) FriendDetailMap {
 
 // Publish a message by sending it to all the channels associated with recips
 func (ppmt *Messanger) Publish(message_text string) {
-	pub_key := EncodePublicKey(ppmt.private_key)
+	ppmt.publishPayload(Payload{text: message_text})
+}
+
+// Fills in the ID, time, group and sequence number of the payload
+// and sends it to every recipient. The payload is encrypted once,
+// with its key wrapped for each recipient, and then signed.
+// A recipient it can't be encrypted for is reported as failed, like
+// one the transport couldn't deliver to.
+func (ppmt *Messanger) publishPayload(payload Payload) {
+	pub_key := PublicKeyToPEM(ppmt.identity.Public())
+	timestamp := time.Now().UnixMilli()
+	ppmt.sequence++
+	payload.message_id = RandomID()
+	payload.timestamp = timestamp
+	payload.group_id = ppmt.group_id
+	payload.sequence = ppmt.sequence
 	message := Message{
-		content:    []byte(message_text),
+		content:    payload.Serialize(),
 		public_key: pub_key,
+		group_id:   ppmt.group_id,
+		timestamp:  timestamp,
+	}
+	recipients := make([]GroupRecipient, len(ppmt.recipients))
+	for i := range ppmt.recipients {
+		friend := &ppmt.recipients[i]
+		recipients[i] = GroupRecipient{public_key: friend.public_key, prekeys: ppmt.prekeysFor(friend)}
+	}
+	errs, err := message.EncryptForGroup(recipients)
+	if err != nil {
+		for i := range errs {
+			errs[i] = fmt.Errorf("could not encrypt message... %w", err)
+		}
 	}
-	// sign the message with your private key then pass along to the channels
-	message.Sign(ppmt.private_key)
-	for _, friend := range ppmt.recipients {
+	message.Sign(ppmt.identity)
+	if batch_transport, ok := ppmt.transport.(BatchMessageTransport); ok {
+		ppmt.publishBatch(batch_transport, message.Serialize(), payload, errs)
+		return
+	}
+	for i, friend := range ppmt.recipients {
+		if errs[i] != nil {
+			ppmt.write_mutex.Lock()
+			ppmt.failed_sends++
+			reportDelivery(&ppmt.recipients[i], errs[i])
+			ppmt.write_mutex.Unlock()
+			continue
+		}
 		friend.message_channel <- message
 		ppmt.wait_group.Add(1)
 	}
 	ppmt.wait_group.Wait()
 }
 
+// Hands the serialized message for every recipient to the transport
+// at once, to be fanned out by the server if it can be. Recipients
+// that already have an error in errs aren't sent anything. The
+// tracker then prints each recipient's status, and updates it as
+// receipts come in.
+func (ppmt *Messanger) publishBatch(transport BatchMessageTransport, content []byte, payload Payload, errs []error) {
+	batch := make([]OutboundMessage, len(ppmt.recipients))
+	var sending []int
+	for i := range ppmt.recipients {
+		batch[i] = OutboundMessage{friend: &ppmt.recipients[i], content: content}
+		if errs[i] == nil {
+			sending = append(sending, i)
+		}
+	}
+	if len(sending) > 0 {
+		friends := make([]*FriendDetail, len(sending))
+		outbound := make([]OutboundMessage, len(sending))
+		for j, i := range sending {
+			friends[j] = batch[i].friend
+			outbound[j] = batch[i]
+		}
+		var sent_errs []error
+		if fanout_transport, ok := transport.(FanoutMessageTransport); ok {
+			sent_errs = fanout_transport.FanoutWriter(friends, content)
+		} else {
+			sent_errs = transport.BatchWriter(outbound)
+		}
+		for j, i := range sending {
+			errs[i] = sent_errs[j]
+		}
+	}
+	ppmt.write_mutex.Lock()
+	defer ppmt.write_mutex.Unlock()
+	for i, outbound := range batch {
+		if errs[i] != nil {
+			ppmt.failed_sends++
+			reportDelivery(outbound.friend, errs[i])
+		}
+	}
+	if ppmt.tracker != nil && !ppmt.quiet {
+		ppmt.tracker.Sent(payload.message_id, payload.text, batch, errs)
+	}
+}
+
 // Readline loop collecting input from user.
 // Sends messages with messanger.Publish() for processing
 func (ppmt *Messanger) WriteLoop() {
@@ -187,13 +735,35 @@ func (ppmt *Messanger) WriteLoop() {
 		panic(err)
 	}
 	defer rl.Close()
+	SetOutput(rl.Stdout())
+	ppmt.readlineLoop(rl)
+}
 
+// Reads and writes in the same terminal.
+// Incoming messages are printed through readline, above the prompt.
+func (ppmt *Messanger) ChatLoop() {
+	rl, err := readline.New("> ")
+	if err != nil {
+		panic(err)
+	}
+	defer rl.Close()
+	SetOutput(rl.Stdout())
+	go ppmt.ReadLoop()
+	ppmt.readlineLoop(rl)
+}
+
+func (ppmt *Messanger) readlineLoop(rl *readline.Instance) {
 	for {
 		line, err := rl.Readline()
 		if err != nil { // io.EOF
 			break
 		}
-		if line != "" {
+		if strings.HasPrefix(line, "/file ") {
+			path := strings.TrimSpace(strings.TrimPrefix(line, "/file "))
+			if err := ppmt.SendFile(path); err != nil {
+				fmt.Fprintln(output, "Could not send", path, "...", err)
+			}
+		} else if line != "" {
 			ppmt.Publish(line)
 		}
 	}
@@ -208,11 +778,11 @@ Incoming byte arrays are handled thusly:
   - added to the Gram Buffer, until a Gram with expect_more == false
   - the grams in the buffer are concatenated
   - the content is unmarshaled into a PBMessage and converted to a Message
-  - the message is decrypted
   - the message signature is verified
+  - the message is decrypted
   - the message content is written to stdout
 */
-func IncomingMessageHandler(friend FriendDetail, write_mutex *sync.Mutex, private_key *rsa.PrivateKey) {
+func IncomingMessageHandler(friend FriendDetail, write_mutex *sync.Mutex, identity Identity) {
 	var gram_content_buffer []byte
 	for raw_gram := range friend.inbound_messages {
 		gram, err := GramFromBytes(raw_gram)
@@ -228,30 +798,43 @@ func IncomingMessageHandler(friend FriendDetail, write_mutex *sync.Mutex, privat
 					"message.signature", message.signature,
 					"message.aes_key", message.aes_key,
 					"message.public_key", message.public_key,
-					"recipient_public_key", private_key.PublicKey,
+					"recipient_public_key", PublicKeyToBytes(identity.Public()),
 				)
 				write_mutex.Unlock()
 			}
 			CheckErrFatal(err)
-			err = message.Decrypt(private_key)
-			CheckErrFatal(err)
 			verified := message.VerifySignature()
 			if !verified {
 				fmt.Println("Could not verify message came from ", friend.name)
 				os.Exit(1)
 			}
+			err = message.Decrypt(identity)
+			CheckErrFatal(err)
+			payload, err := PayloadFromBytes(message.content)
+			CheckErrFatal(err)
 			write_mutex.Lock()
 			fmt.Printf(
-				"%v\n%v\n\n", friend.name, string(message.content),
+				"%v\n%v\n\n", friend.name, payload.text,
 			)
 			write_mutex.Unlock()
 		}
 	}
 }
 
+// Reads receipts for the messages being written, leaving everything
+// else in the mailbox for the reader.
+func (ppmt *Messanger) WatchReceipts() {
+	webt, ok := ppmt.transport.(*WEBTransport)
+	if !ok {
+		return
+	}
+	webt.receipts_only = true
+	webt.Reader()
+}
+
 func (ppmt *Messanger) ReadLoop() {
 	// start the message handler
-	fmt.Println("Listening for messages...")
+	fmt.Fprintln(output, "Listening for messages...")
 	ppmt.transport.Reader()
 }
 
@@ -264,6 +847,7 @@ func ConfigureMessanger(config *MessangerConfig) *Messanger {
 	var friends []FriendDetail
 	var transport MessageTransport
 	write_mutex := &sync.Mutex{}
+	verified := loadVerifiedOrWarn(config.VerifiedFile)
 	for _, recip := range config.Users {
 		pub_key, err := ParsePublicKey([]byte(recip.Key))
 		CheckErrFatal(err)
@@ -272,47 +856,126 @@ func ConfigureMessanger(config *MessangerConfig) *Messanger {
 			message_channel:  make(chan Message),
 			inbound_messages: make(chan []byte),
 			name:             recip.Name,
+			verified:         verified.Has(pub_key),
 		})
 	}
 	// Add yourself
 	friends = append(friends, FriendDetail{
-		public_key:       &config.PrivateKey.PublicKey,
+		public_key:       config.Identity.Public(),
 		message_channel:  make(chan Message),
 		inbound_messages: make(chan []byte),
 		name:             "Yourself",
 	})
+	tracker := NewDeliveryTracker(config.Identity.Public())
+	other_groups := map[string]GroupDetail{}
+	for _, group := range config.OtherGroups {
+		other_groups[group.GroupID] = GroupDetail{name: group.Name, friends: parseFriendMap(group.Users, verified)}
+	}
 	transport = &WEBTransport{
-		friends:     friends,
-		host_url:    config.URL,
-		private_key: config.PrivateKey,
+		friends:         friends,
+		host_url:        config.URL,
+		identity:        config.Identity,
+		group_id:        config.GroupID,
+		other_groups:    other_groups,
+		prekey_file:     config.PrekeyFile,
+		config_file:     config.ConfigFile,
+		tracker:         tracker,
+		read_receipts:   config.ReadReceipts,
+		http_client:     NewHTTPClient(config.TLSFingerprint),
+		tls_fingerprint: config.TLSFingerprint,
+		files_dir:       config.FilesDir,
+		device_id:       config.DeviceID,
 	}
 	wg := sync.WaitGroup{}
 	return &Messanger{
 		recipients:  friends,
 		wait_group:  &wg,
-		private_key: config.PrivateKey,
+		identity:    config.Identity,
 		transport:   transport,
 		write_mutex: write_mutex,
 		port:        config.Port,
+		group_id:    config.GroupID,
+		tracker:     tracker,
+		// counting from the clock keeps sequence numbers increasing across runs
+		sequence:  uint64(time.Now().UnixMicro()),
+		files_dir: config.FilesDir,
 	}
 }
 
+// Returns the friend's prekeys if the transport can look them up.
+// When it can but the friend has none that verify, the message is
+// wrapped for their long-term key instead and has no forward
+// secrecy, so the user is told, once per friend.
+func (ppmt *Messanger) prekeysFor(friend *FriendDetail) [][]byte {
+	prekey_transport, ok := ppmt.transport.(PrekeyTransport)
+	if !ok {
+		return nil
+	}
+	prekeys := prekey_transport.Prekeys(friend)
+	if len(prekeys) > 0 || PublicKeyToString(friend.public_key) == PublicKeyToString(ppmt.identity.Public()) {
+		return prekeys
+	}
+	ppmt.write_mutex.Lock()
+	defer ppmt.write_mutex.Unlock()
+	key := PublicKeyToString(friend.public_key)
+	if ppmt.quiet || ppmt.warned_no_prekeys[key] {
+		return prekeys
+	}
+	if ppmt.warned_no_prekeys == nil {
+		ppmt.warned_no_prekeys = map[string]bool{}
+	}
+	ppmt.warned_no_prekeys[key] = true
+	fmt.Fprintln(output, "Warning:", friend.name, "has no prekeys on the relay, so messages to them are encrypted for their long-term key, without forward secrecy")
+	return prekeys
+}
+
+// Builds a friend map for a group that isn't being written to.
+// Keys that don't parse are left out.
+func parseFriendMap(users []RecipientConfig, verified VerifiedKeys) FriendDetailMap {
+	friend_map := FriendDetailMap{}
+	for _, recip := range users {
+		pub_key, err := ParsePublicKey([]byte(recip.Key))
+		if err != nil {
+			continue
+		}
+		friend_map[PublicKeyToString(pub_key)] = FriendDetail{public_key: pub_key, name: recip.Name, verified: verified.Has(pub_key)}
+	}
+	return friend_map
+}
+
+// Loads the verified keys, carrying on with nobody verified if
+// the file can't be read. No file means the same.
+func loadVerifiedOrWarn(path string) VerifiedKeys {
+	if path == "" {
+		return VerifiedKeys{}
+	}
+	verified, err := LoadVerifiedKeys(path)
+	if err != nil {
+		fmt.Fprintln(output, err)
+		return VerifiedKeys{}
+	}
+	return verified
+}
+
 // Sets up goroutines for each recipient and then returns.
+// Transports that send in batches don't need them.
 func (ppmt *Messanger) OutboundConnect() {
+	if _, ok := ppmt.transport.(BatchMessageTransport); ok {
+		return
+	}
 	for i := range ppmt.recipients {
-		go sendAndReport(ppmt.wait_group, &ppmt.recipients[i], ppmt.transport, ppmt.write_mutex)
+		go ppmt.sendAndReport(&ppmt.recipients[i])
 	}
 }
 
-// Listens for data sent to a channel, prep and send it via the transport.
-// Blocks the main thread until done.
-func sendAndReport(wg *sync.WaitGroup, friend *FriendDetail, transport MessageTransport, write_mutex *sync.Mutex) {
+// Listens for signed messages sent to a channel and sends them via
+// the transport. Blocks the main thread until done.
+func (ppmt *Messanger) sendAndReport(friend *FriendDetail) {
 
 	for message := range friend.message_channel {
-		message.Encrypt(friend.public_key)
 		serialized_message := message.Serialize()
-		err := transport.Writer(friend, serialized_message)
-		write_mutex.Lock()
+		err := ppmt.transport.Writer(friend, serialized_message)
+		ppmt.write_mutex.Lock()
 		if CheckDebug() {
 			slog.Debug(
 				"Sending serialized message.",
@@ -326,11 +989,22 @@ func sendAndReport(wg *sync.WaitGroup, friend *FriendDetail, transport MessageTr
 			)
 		}
 		if err != nil {
-			fmt.Println("Could not send message to", friend.name, "...", err, X_MARK)
-		} else {
-			fmt.Printf("%v:\u2705\n", friend.name)
+			ppmt.failed_sends++
+		}
+		if err != nil || !ppmt.quiet {
+			reportDelivery(friend, err)
 		}
-		write_mutex.Unlock()
-		wg.Done()
+		ppmt.write_mutex.Unlock()
+		ppmt.wait_group.Done()
+	}
+}
+
+// Tells the user whether the message made it to the friend.
+// Callers should hold the write mutex.
+func reportDelivery(friend *FriendDetail, err error) {
+	if err != nil {
+		fmt.Fprintln(output, "Could not send message to", friend.name, "...", err, X_MARK)
+	} else {
+		fmt.Fprintf(output, "%v: sent %v\n", friend.name, MEDIUM_CHECK_MARK)
 	}
 }
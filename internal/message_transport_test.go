@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -10,7 +11,7 @@ import (
 func TestConfigTransport(t *testing.T) {
 	viper.SetConfigName("sample_config")
 	viper.SetConfigType("toml")
-	viper.AddConfigPath("..")
+	viper.AddConfigPath(".")
 	err := viper.ReadInConfig()
 	if err != nil {
 		t.Errorf("could not load viper config: %v", err)
@@ -21,6 +22,12 @@ func TestConfigTransport(t *testing.T) {
 	if config.Users[1].Name != "Bill" {
 		t.Errorf("2nd User name is wrong, %v", config.Users[1].Name)
 	}
+	if config.GroupID != "group_two" {
+		t.Errorf("group ID should default to the group name, got %v", config.GroupID)
+	}
+	if len(config.OtherGroups) != 1 || config.OtherGroups[0].GroupID != "group_one" {
+		t.Errorf("expected group_one as the only other group, got %v", config.OtherGroups)
+	}
 	messanger := ConfigureMessanger(config)
 	recip_private_key := GenerateRandomKey()
 	if err != nil {
@@ -28,16 +35,170 @@ func TestConfigTransport(t *testing.T) {
 	}
 	message := Message{
 		content:    []byte("Hello"),
-		public_key: PublicKeyToBytes(&messanger.private_key.PublicKey),
+		public_key: PublicKeyToPEM(messanger.identity.Public()),
+		group_id:   messanger.group_id,
 	}
-	message.Sign(messanger.private_key)
 	message.Encrypt(&recip_private_key.PublicKey)
-	err = message.Decrypt(recip_private_key)
+	message.Sign(messanger.identity)
+	err = message.Verify(&recip_private_key.PublicKey)
+	if err != nil {
+		t.Error(err)
+	}
+	err = message.Decrypt(NewKeyIdentity(recip_private_key))
 	if err != nil {
 		t.Error(err)
 	}
-	message.VerifySignature()
 	if string(message.content) != "Hello" {
 		t.Errorf("decrypted message content is not as expected: %v", string(message.content))
 	}
 }
+
+// A signed message must not verify for any other recipient or group,
+// or once any signed field is changed.
+func TestMessageVerifyRejects(t *testing.T) {
+	sender := GenerateRandomKey()
+	recipient := GenerateRandomKey()
+	newMessage := func() Message {
+		message := Message{
+			content:    []byte("Hello"),
+			public_key: EncodePublicKey(sender),
+			group_id:   "friends",
+			timestamp:  1700000000000,
+		}
+		message.Encrypt(&recipient.PublicKey)
+		message.Sign(NewKeyIdentity(sender))
+		return message
+	}
+	message := newMessage()
+	parsed, err := MessageFromBytes(message.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.Verify(&recipient.PublicKey); err != nil {
+		t.Errorf("round tripped message did not verify: %v", err)
+	}
+	if err := message.Verify(&sender.PublicKey); err == nil {
+		t.Error("message verified for a different recipient")
+	}
+	tampered := newMessage()
+	tampered.group_id = "enemies"
+	if tampered.VerifySignature() {
+		t.Error("signature still verified after the group changed")
+	}
+	tampered = newMessage()
+	tampered.timestamp++
+	if tampered.VerifySignature() {
+		t.Error("signature still verified after the timestamp changed")
+	}
+	tampered = newMessage()
+	tampered.content[0] ^= 1
+	if tampered.VerifySignature() {
+		t.Error("signature still verified after the ciphertext changed")
+	}
+	// re-encrypting for someone else breaks the signature
+	forwarded := newMessage()
+	forwarded.Decrypt(NewKeyIdentity(recipient))
+	forwarded.Encrypt(&sender.PublicKey)
+	if err := forwarded.Verify(&sender.PublicKey); err == nil {
+		t.Error("forwarded message verified for the new recipient")
+	}
+}
+
+// A group message is encrypted once, and every member can read it
+// with their own kind of key.
+func TestGroupMessage(t *testing.T) {
+	sender := GenerateRandomKey()
+	rsa_member := GenerateRandomKey()
+	ed25519_member, _ := GenerateKey(KEY_TYPE_ED25519)
+	prekey_member := GenerateRandomKey()
+	store, _ := OpenPrekeyStore(filepath.Join(t.TempDir(), "id_rsa.prekeys"))
+	prekey, err := store.Refresh(NewKeyIdentity(prekey_member))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newMessage := func() Message {
+		message := Message{content: []byte("Hello"), public_key: EncodePublicKey(sender), group_id: "friends"}
+		_, err := message.EncryptForGroup([]GroupRecipient{
+			{public_key: &rsa_member.PublicKey},
+			{public_key: ed25519_member.Public()},
+			{public_key: &prekey_member.PublicKey, prekeys: [][]byte{prekey.PublicKey}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		message.Sign(NewKeyIdentity(sender))
+		parsed, _ := MessageFromBytes(message.Serialize())
+		return parsed
+	}
+	for _, member := range []Identity{NewKeyIdentity(rsa_member), NewKeyIdentity(ed25519_member)} {
+		message := newMessage()
+		if err := message.Verify(member.Public()); err != nil {
+			t.Fatal(err)
+		}
+		if err := message.Decrypt(member); err != nil || string(message.content) != "Hello" {
+			t.Errorf("a %T member could not read the message: %v", member.Public(), err)
+		}
+	}
+	message := newMessage()
+	if err := message.Verify(&prekey_member.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := message.DecryptWithPrekeys(store); err != nil || string(message.content) != "Hello" {
+		t.Errorf("the prekey member could not read the message: %v", err)
+	}
+
+	outsider := GenerateRandomKey()
+	message = newMessage()
+	if err := message.Verify(&outsider.PublicKey); err == nil {
+		t.Error("message verified for someone outside the group")
+	}
+	tampered := newMessage()
+	tampered.recipients[0].aes_key[0] ^= 1
+	if tampered.VerifySignature() {
+		t.Error("signature still verified after a member's key changed")
+	}
+	dropped := newMessage()
+	dropped.recipients = dropped.recipients[1:]
+	if dropped.VerifySignature() {
+		t.Error("signature still verified after a member was dropped")
+	}
+}
+
+func TestPayloadRoundTrip(t *testing.T) {
+	payload := Payload{
+		message_id: RandomID(),
+		timestamp:  1700000000000,
+		group_id:   "friends",
+		sequence:   42,
+		text:       "Hello",
+	}
+	parsed, err := PayloadFromBytes(payload.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != payload {
+		t.Errorf("payload changed in a round trip: %v", parsed)
+	}
+	envelope := Message{group_id: "friends", timestamp: 1700000000000}
+	if !parsed.MatchesEnvelope(&envelope) {
+		t.Error("payload should match its envelope")
+	}
+	envelope.group_id = "enemies"
+	if parsed.MatchesEnvelope(&envelope) {
+		t.Error("payload should not match an envelope for another group")
+	}
+}
+
+func TestSeenMessages(t *testing.T) {
+	seen := newSeenMessages(2)
+	if !seen.Add("a") || !seen.Add("b") {
+		t.Fatal("new IDs should be added")
+	}
+	if seen.Add("a") {
+		t.Error("a duplicate ID was added")
+	}
+	seen.Add("c")
+	if !seen.Add("a") {
+		t.Error("the oldest ID should be forgotten past the limit")
+	}
+}
@@ -11,9 +11,16 @@ which are []byte of length <= 1024
 package internal
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 )
@@ -23,16 +30,77 @@ const (
 	// Marshalling as Protobuf adds 5 bytes.
 	// We'll use 1000 bytes here to give some leeway
 	GRAM_SIZE = 1000
+	// How far in the future a message timestamp may be before it is flagged.
+	MESSAGE_CLOCK_SKEW = time.Minute * 5
 )
 
 // This is sent by the writer, and is unconcerned with the transport.
 // The public key here is the public key of the
 // message writer, not the message reader.
+// The signature is made after encryption and covers the ciphertext,
+// the encrypted AES key, both keys, the group and the timestamp,
+// so a message can't be passed off as sent to anyone else.
 type Message struct {
-	content    []byte
-	signature  []byte
-	aes_key    []byte
-	public_key []byte
+	content       []byte
+	signature     []byte
+	aes_key       []byte
+	public_key    []byte
+	recipient_key []byte
+	group_id      string
+	// unix milliseconds
+	timestamp int64
+	// set instead of aes_key for X25519 keys
+	wrapped_keys        []WrappedKey
+	signature_algorithm PBSignatureAlgorithm
+	key_algorithm       PBKeyAlgorithm
+	// set instead of the three above for a message to a whole group
+	recipients []RecipientKey
+}
+
+// The AES key of a group message, wrapped for one member.
+type RecipientKey struct {
+	key_id        []byte
+	key_algorithm PBKeyAlgorithm
+	aes_key       []byte
+	wrapped_keys  []WrappedKey
+}
+
+// The AES key of a message wrapped for one of the recipient's prekeys.
+type WrappedKey struct {
+	prekey        []byte
+	ephemeral_key []byte
+	wrapped_key   []byte
+}
+
+// What the sender wrote, along with the details needed to place
+// and order it. This is the content of a Message before encryption,
+// so the server never sees it.
+type Payload struct {
+	message_id string
+	// unix milliseconds
+	timestamp int64
+	group_id  string
+	sequence  uint64
+	text      string
+	// set when the payload is a receipt rather than a message
+	receipt *Receipt
+	// set when someone is joining with an invite
+	introduction *Introduction
+	// set when the sender is replacing their key
+	rotation *PBRotation
+	// set when the sender offers a file, with text saying so
+	file *PBFileOffer
+}
+
+type Introduction struct {
+	invite *PBInvite
+	name   string
+}
+
+// Tells the writer of a message how far it got.
+type Receipt struct {
+	message_id string
+	status     PBReceiptStatus
 }
 
 // Serialized messages (PBMessage) are split into chunks, or 'Grams'.
@@ -45,25 +113,158 @@ type Gram struct {
 	expect_more bool
 }
 
-// Encrypts the Message content, modifying the Message in place
-func (message *Message) Encrypt(pub_key *rsa.PublicKey) {
+// Encrypts the Message content for the given recipient,
+// modifying the Message in place. The AES key is wrapped with RSA-OAEP
+// for an RSA key, and with X25519 for an Ed25519 key.
+// Sign the message after this.
+func (message *Message) Encrypt(pub_key crypto.PublicKey) {
+	err := message.EncryptForPrekeys(pub_key, nil)
+	CheckErrFatal(err)
+}
+
+// Encrypts the Message content so that only the holder of one of the
+// recipient's prekeys can read it. The AES key is wrapped for each
+// prekey with a key agreed with a fresh ephemeral key, so once the
+// recipient deletes the prekey nothing can recover it, not even
+// their long-term key. Without prekeys this is the same as Encrypt.
+// Sign the message after this.
+func (message *Message) EncryptForPrekeys(pub_key crypto.PublicKey, prekeys [][]byte) error {
 	new_aes_key := GenerateRandomAESKey()
+	recipient, err := wrapAESKey(new_aes_key, pub_key, prekeys)
+	if err != nil {
+		return err
+	}
 	ciphertext, err := AESEncrypt(message.content, new_aes_key)
-	CheckErrFatal(err)
-	encrypted_aes_key, err := RSAEncrypt(pub_key, new_aes_key)
-	CheckErrFatal(err)
+	if err != nil {
+		return err
+	}
 	message.content = ciphertext
-	message.aes_key = encrypted_aes_key
+	message.useRecipientKey(recipient)
+	message.recipient_key = PublicKeyToBytes(pub_key)
+	return nil
+}
+
+// A member of the group a message is encrypted for.
+type GroupRecipient struct {
+	public_key crypto.PublicKey
+	// empty if they haven't published any
+	prekeys [][]byte
 }
 
-// Decrypts the Message content, modifying the Message in place
-func (message *Message) Decrypt(priv_key *rsa.PrivateKey) error {
-	decrypted_aes_key, err := RSADecrypt(priv_key, message.aes_key)
+// Encrypts the Message content once for the whole group, with the
+// AES key wrapped for each member the same way EncryptForPrekeys
+// would. The serialized message can then go to every one of them.
+// Sign the message after this.
+//
+// A member the key can't be wrapped for is left out of the message,
+// with their error at the same index of the returned slice, so one
+// bad key doesn't stop the message going to everyone else. The
+// second error is for when the content itself can't be encrypted.
+func (message *Message) EncryptForGroup(recipients []GroupRecipient) ([]error, error) {
+	new_aes_key := GenerateRandomAESKey()
+	errs := make([]error, len(recipients))
+	message.recipients = nil
+	for i, recipient := range recipients {
+		wrapped, err := wrapAESKey(new_aes_key, recipient.public_key, recipient.prekeys)
+		if err != nil {
+			errs[i] = fmt.Errorf("could not encrypt message for recipient... %w", err)
+			continue
+		}
+		wrapped.key_id = recipientKeyID(recipient.public_key)
+		message.recipients = append(message.recipients, wrapped)
+	}
+	ciphertext, err := AESEncrypt(message.content, new_aes_key)
 	if err != nil {
-		return fmt.Errorf("unable to decrypt AES key... %w", err)
+		return errs, err
+	}
+	message.content = ciphertext
+	return errs, nil
+}
+
+// Identifies a group member's entry in a message's recipients.
+func recipientKeyID(pub_key crypto.PublicKey) []byte {
+	hash := sha256.Sum256(PublicKeyToBytes(pub_key))
+	return hash[:16]
+}
+
+// Wraps the AES key for the recipient's prekeys if they have any,
+// otherwise for their long-term key.
+func wrapAESKey(aes_key []byte, pub_key crypto.PublicKey, prekeys [][]byte) (RecipientKey, error) {
+	if len(prekeys) > 0 {
+		wrapped_keys, err := wrapForX25519(aes_key, prekeys)
+		return RecipientKey{key_algorithm: PBKeyAlgorithm_KEY_X25519_PREKEY, wrapped_keys: wrapped_keys}, err
+	}
+	switch key := pub_key.(type) {
+	case ed25519.PublicKey:
+		x25519_key, err := X25519PublicFromEd25519(key)
+		if err != nil {
+			return RecipientKey{}, err
+		}
+		wrapped_keys, err := wrapForX25519(aes_key, [][]byte{x25519_key})
+		return RecipientKey{key_algorithm: PBKeyAlgorithm_KEY_X25519_IDENTITY, wrapped_keys: wrapped_keys}, err
+	case *rsa.PublicKey:
+		encrypted_aes_key, err := RSAEncrypt(key, aes_key)
+		return RecipientKey{key_algorithm: PBKeyAlgorithm_KEY_RSA_OAEP, aes_key: encrypted_aes_key}, err
+	}
+	return RecipientKey{}, fmt.Errorf("cannot encrypt for key type %T", pub_key)
+}
+
+// Wraps the AES key for each of the X25519 public keys using one
+// ephemeral key.
+func wrapForX25519(aes_key []byte, x25519_keys [][]byte) ([]WrappedKey, error) {
+	ephemeral_private, ephemeral_public := GenerateX25519Key()
+	wrapped_keys := make([]WrappedKey, len(x25519_keys))
+	for i, x25519_key := range x25519_keys {
+		wrapping_key, err := DeriveX25519Key(ephemeral_private, x25519_key, wrappingInfo(ephemeral_public, x25519_key))
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := AESEncrypt(aes_key, wrapping_key)
+		if err != nil {
+			return nil, err
+		}
+		wrapped_keys[i] = WrappedKey{prekey: x25519_key, ephemeral_key: ephemeral_public, wrapped_key: wrapped}
+	}
+	return wrapped_keys, nil
+}
+
+// Puts the recipient's wrapped key where Decrypt looks for it.
+func (message *Message) useRecipientKey(recipient RecipientKey) {
+	message.key_algorithm = recipient.key_algorithm
+	message.aes_key = recipient.aes_key
+	message.wrapped_keys = recipient.wrapped_keys
+}
+
+func wrappingInfo(ephemeral_key []byte, prekey []byte) []byte {
+	info := []byte("peppermint-wrap-v1")
+	info = append(info, ephemeral_key...)
+	return append(info, prekey...)
+}
 
+// Unwraps the AES key with whichever X25519 private key find returns
+// for one of the wrapped keys.
+func (message *Message) unwrapX25519Key(find func(public_key []byte) []byte) ([]byte, error) {
+	for _, wrapped := range message.wrapped_keys {
+		private_key := find(wrapped.prekey)
+		if private_key == nil {
+			continue
+		}
+		wrapping_key, err := DeriveX25519Key(private_key, wrapped.ephemeral_key, wrappingInfo(wrapped.ephemeral_key, wrapped.prekey))
+		if err != nil {
+			return nil, err
+		}
+		aes_key, err := AESDecrypt(wrapped.wrapped_key, wrapping_key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unwrap AES key... %w", err)
+		}
+		return aes_key, nil
 	}
-	decrypted_content, err := AESDecrypt(message.content, decrypted_aes_key)
+	return nil, errors.New("message was not wrapped for any key we have")
+}
+
+// Decrypts the content in place with the unwrapped AES key.
+func (message *Message) decryptContent(aes_key []byte) error {
+	decrypted_content, err := AESDecrypt(message.content, aes_key)
 	if err != nil {
 		return fmt.Errorf("unable to decrypt message... %w", err)
 	}
@@ -71,18 +272,126 @@ func (message *Message) Decrypt(priv_key *rsa.PrivateKey) error {
 	return nil
 }
 
+// Decrypts a Message that was wrapped for prekeys, using whichever
+// of ours it was wrapped for. Modifies the Message in place.
+func (message *Message) DecryptWithPrekeys(store *PrekeyStore) error {
+	if store == nil {
+		return errors.New("message was encrypted for prekeys, but there is no prekey store")
+	}
+	aes_key, err := message.unwrapX25519Key(store.Find)
+	if err != nil {
+		return fmt.Errorf("message was encrypted for a prekey we no longer have... %w", err)
+	}
+	return message.decryptContent(aes_key)
+}
+
+// Decrypts the Message content with our long-term identity,
+// modifying the Message in place.
+func (message *Message) Decrypt(identity Identity) error {
+	if message.key_algorithm == PBKeyAlgorithm_KEY_X25519_PREKEY {
+		return fmt.Errorf("message was encrypted with %v, which needs a prekey", message.key_algorithm)
+	}
+	aes_key, err := identity.UnwrapKey(message)
+	if err != nil {
+		return err
+	}
+	return message.decryptContent(aes_key)
+}
+
+// Builds the bytes that get signed for an encrypted message.
+// Each field is length prefixed so no two messages share a signing text.
+func (message *Message) signingText() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("peppermint-message-v1")
+	for _, field := range [][]byte{
+		binary.BigEndian.AppendUint32(nil, uint32(message.signature_algorithm)),
+		binary.BigEndian.AppendUint32(nil, uint32(message.key_algorithm)),
+		message.public_key,
+		message.recipient_key,
+		[]byte(message.group_id),
+		binary.BigEndian.AppendUint64(nil, uint64(message.timestamp)),
+		message.aes_key,
+		message.content,
+	} {
+		buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+		buffer.Write(field)
+	}
+	writeWrappedKeys(&buffer, message.wrapped_keys)
+	if len(message.recipients) > 0 {
+		buffer.WriteString("recipients")
+		buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(len(message.recipients))))
+	}
+	for _, recipient := range message.recipients {
+		for _, field := range [][]byte{
+			recipient.key_id,
+			binary.BigEndian.AppendUint32(nil, uint32(recipient.key_algorithm)),
+			recipient.aes_key,
+			binary.BigEndian.AppendUint32(nil, uint32(len(recipient.wrapped_keys))),
+		} {
+			buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+			buffer.Write(field)
+		}
+		writeWrappedKeys(&buffer, recipient.wrapped_keys)
+	}
+	return buffer.Bytes()
+}
+
+func writeWrappedKeys(buffer *bytes.Buffer, wrapped_keys []WrappedKey) {
+	for _, wrapped := range wrapped_keys {
+		for _, field := range [][]byte{wrapped.prekey, wrapped.ephemeral_key, wrapped.wrapped_key} {
+			buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+			buffer.Write(field)
+		}
+	}
+}
+
+// Checks the signature against the sender's key on the message.
+// Call this before decrypting, since the signature covers the ciphertext.
 func (message *Message) VerifySignature() bool {
 	pub_key, err := ParsePublicKey(message.public_key)
 	if err != nil {
 		fmt.Println("Could not parse public key on the message: ", err)
 		return false
 	}
-	// fmt.Printf("Tyring to verify content: %v", string(message.content))
-	return RSAVerify(pub_key, message.content, message.signature)
+	return VerifyText(pub_key, message.signature_algorithm, message.signingText(), message.signature)
 }
 
-func (message *Message) Sign(private_key *rsa.PrivateKey) {
-	signature, err := RSASign(private_key, message.content)
+// Checks that the message was signed by its sender and was
+// meant for the given recipient. The group is left to the caller.
+// For a message to a whole group, the recipient's wrapped key is
+// picked out so it can be decrypted like any other, which means the
+// signature won't check out a second time.
+func (message *Message) Verify(recipient crypto.PublicKey) error {
+	if !message.VerifySignature() {
+		return errors.New("signature does not match the sender's key")
+	}
+	if len(message.recipients) > 0 {
+		key_id := recipientKeyID(recipient)
+		for _, entry := range message.recipients {
+			if bytes.Equal(entry.key_id, key_id) {
+				message.useRecipientKey(entry)
+				message.recipient_key = PublicKeyToBytes(recipient)
+				return nil
+			}
+		}
+		return errors.New("message was encrypted for someone else")
+	}
+	if !bytes.Equal(message.recipient_key, PublicKeyToBytes(recipient)) {
+		return errors.New("message was encrypted for someone else")
+	}
+	return nil
+}
+
+// Whether the sender's clock put the message too far in the future.
+func (message *Message) FromTheFuture() bool {
+	return time.UnixMilli(message.timestamp).After(time.Now().Add(MESSAGE_CLOCK_SKEW))
+}
+
+// Signs the encrypted message, see signingText.
+// The algorithm is set first, since it is signed too.
+func (message *Message) Sign(identity Identity) {
+	message.signature_algorithm = identity.SignatureAlgorithm()
+	signature, err := identity.Sign(message.signingText())
 	CheckErrFatal(err)
 	message.signature = signature
 }
@@ -91,10 +400,24 @@ func (message *Message) Sign(private_key *rsa.PrivateKey) {
 // marshaling it to bytes.
 func (message *Message) Serialize() []byte {
 	new_pb := &PBMessage{
-		Content:   message.content,
-		Signature: message.signature,
-		AesKey:    message.aes_key,
-		PublicKey: message.public_key,
+		Content:            message.content,
+		Signature:          message.signature,
+		AesKey:             message.aes_key,
+		PublicKey:          message.public_key,
+		RecipientKey:       message.recipient_key,
+		GroupId:            message.group_id,
+		Timestamp:          message.timestamp,
+		SignatureAlgorithm: message.signature_algorithm,
+		KeyAlgorithm:       message.key_algorithm,
+	}
+	new_pb.WrappedKeys = wrappedKeysToPB(message.wrapped_keys)
+	for _, recipient := range message.recipients {
+		new_pb.Recipients = append(new_pb.Recipients, &PBRecipientKey{
+			KeyId:        recipient.key_id,
+			KeyAlgorithm: recipient.key_algorithm,
+			AesKey:       recipient.aes_key,
+			WrappedKeys:  wrappedKeysToPB(recipient.wrapped_keys),
+		})
 	}
 	data, err := proto.Marshal(new_pb)
 	CheckErrFatal(err)
@@ -104,14 +427,130 @@ func (message *Message) Serialize() []byte {
 func MessageFromBytes(buffer []byte) (Message, error) {
 	new_message := &PBMessage{}
 	err := proto.Unmarshal(buffer, new_message)
+	var recipients []RecipientKey
+	for _, recipient := range new_message.Recipients {
+		recipients = append(recipients, RecipientKey{
+			key_id:        recipient.KeyId,
+			key_algorithm: recipient.KeyAlgorithm,
+			aes_key:       recipient.AesKey,
+			wrapped_keys:  wrappedKeysFromPB(recipient.WrappedKeys),
+		})
+	}
 	return Message{
-		content:    new_message.Content,
-		signature:  new_message.Signature,
-		aes_key:    new_message.AesKey,
-		public_key: new_message.PublicKey,
+		content:             new_message.Content,
+		signature:           new_message.Signature,
+		aes_key:             new_message.AesKey,
+		public_key:          new_message.PublicKey,
+		recipient_key:       new_message.RecipientKey,
+		group_id:            new_message.GroupId,
+		timestamp:           new_message.Timestamp,
+		wrapped_keys:        wrappedKeysFromPB(new_message.WrappedKeys),
+		signature_algorithm: new_message.SignatureAlgorithm,
+		key_algorithm:       new_message.KeyAlgorithm,
+		recipients:          recipients,
 	}, err
 }
 
+func wrappedKeysToPB(wrapped_keys []WrappedKey) []*PBWrappedKey {
+	var pb_keys []*PBWrappedKey
+	for _, wrapped := range wrapped_keys {
+		pb_keys = append(pb_keys, &PBWrappedKey{
+			Prekey:       wrapped.prekey,
+			EphemeralKey: wrapped.ephemeral_key,
+			WrappedKey:   wrapped.wrapped_key,
+		})
+	}
+	return pb_keys
+}
+
+func wrappedKeysFromPB(pb_keys []*PBWrappedKey) []WrappedKey {
+	var wrapped_keys []WrappedKey
+	for _, wrapped := range pb_keys {
+		wrapped_keys = append(wrapped_keys, WrappedKey{
+			prekey:        wrapped.Prekey,
+			ephemeral_key: wrapped.EphemeralKey,
+			wrapped_key:   wrapped.WrappedKey,
+		})
+	}
+	return wrapped_keys
+}
+
+func (payload *Payload) Serialize() []byte {
+	new_pb := &PBPayload{
+		MessageId: payload.message_id,
+		Timestamp: payload.timestamp,
+		GroupId:   payload.group_id,
+		Sequence:  payload.sequence,
+		Text:      payload.text,
+	}
+	if payload.receipt != nil {
+		new_pb.Receipt = &PBReceipt{
+			MessageId: payload.receipt.message_id,
+			Status:    payload.receipt.status,
+		}
+	}
+	if payload.introduction != nil {
+		new_pb.Introduction = &PBIntroduction{
+			Invite: payload.introduction.invite,
+			Name:   payload.introduction.name,
+		}
+	}
+	new_pb.Rotation = payload.rotation
+	new_pb.File = payload.file
+	data, err := proto.Marshal(new_pb)
+	CheckErrFatal(err)
+	return data
+}
+
+func PayloadFromBytes(buffer []byte) (Payload, error) {
+	new_payload := &PBPayload{}
+	err := proto.Unmarshal(buffer, new_payload)
+	payload := Payload{
+		message_id: new_payload.MessageId,
+		timestamp:  new_payload.Timestamp,
+		group_id:   new_payload.GroupId,
+		sequence:   new_payload.Sequence,
+		text:       new_payload.Text,
+	}
+	if new_payload.Receipt != nil {
+		payload.receipt = &Receipt{
+			message_id: new_payload.Receipt.MessageId,
+			status:     new_payload.Receipt.Status,
+		}
+	}
+	if new_payload.Introduction != nil {
+		payload.introduction = &Introduction{
+			invite: new_payload.Introduction.Invite,
+			name:   new_payload.Introduction.Name,
+		}
+	}
+	payload.rotation = new_payload.Rotation
+	payload.file = new_payload.File
+	return payload, err
+}
+
+// Encrypts the payload for the recipient's prekeys (or their long-term
+// key if there are none), signs it and serializes it.
+func SealPayload(payload Payload, identity Identity, recipient crypto.PublicKey, prekeys [][]byte) ([]byte, error) {
+	message := Message{
+		content:    payload.Serialize(),
+		public_key: PublicKeyToPEM(identity.Public()),
+		group_id:   payload.group_id,
+		timestamp:  payload.timestamp,
+	}
+	err := message.EncryptForPrekeys(recipient, prekeys)
+	if err != nil {
+		return nil, err
+	}
+	message.Sign(identity)
+	return message.Serialize(), nil
+}
+
+// Whether the decrypted payload agrees with the signed envelope it came in.
+func (payload *Payload) MatchesEnvelope(message *Message) bool {
+	return payload.group_id == message.group_id && payload.timestamp == message.timestamp
+}
+
 func (gram *Gram) Serialize() []byte {
 	new_pb := &PBGram{
 		Content:    gram.content,
@@ -1,21 +1,46 @@
 package internal
 
+import "os"
+
 type TRANSPORT_TYPE int
 type READ_OR_WRITE int
 
 const (
 	READ READ_OR_WRITE = iota
 	WRITE
+	// read and write over a single websocket session
+	CHAT
+	// CHAT in a full-screen UI, see tui.go
+	TUI_MODE
+	// READ writing JSON lines for other programs, see jsonl.go
+	READ_JSONL
 )
 
 // Set up the transport and begin the Write or Read loop
+// A writer with its input piped in sends each line and exits.
 func MessageEntrypoint(action READ_OR_WRITE, config *MessangerConfig) {
+	if action == WRITE && !HasTerminal() {
+		SendToGroup(config, "", false, false)
+		return
+	}
+	if action == READ_JSONL {
+		// stdout is kept for the JSON, anything else goes to stderr
+		SetOutput(os.Stderr)
+		SetDisplay(NewJSONDisplay(os.Stdout, config))
+	}
 	messanger := ConfigureMessanger(config)
 	if action == WRITE {
 		messanger.OutboundConnect()
+		go messanger.WatchReceipts()
 		messanger.WriteLoop()
-	} else if action == READ {
+	} else if action == READ || action == READ_JSONL {
 		messanger.ReadLoop()
+	} else if action == CHAT {
+		messanger.transport = NewWSTransport(messanger.transport.(*WEBTransport))
+		messanger.ChatLoop()
+	} else if action == TUI_MODE {
+		messanger.transport = NewWSTransport(messanger.transport.(*WEBTransport))
+		RunTUI(config, messanger)
 	} else {
 		panic("Illegal action type provided")
 	}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Reports whether stdin is a terminal we can ask for passphrases on.
+func HasTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// Asks for a passphrase on the terminal without echoing it.
+func PromptPassphrase(prompt string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !HasTerminal() {
+		return nil, errors.New("the key file is encrypted, and there is no terminal to ask for its passphrase")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("could not read passphrase... %w", err)
+	}
+	return passphrase, nil
+}
+
+// Asks for a new passphrase twice, the way ssh-keygen does.
+// An empty passphrase means the key is stored unencrypted.
+func PromptNewPassphrase() ([]byte, error) {
+	passphrase, err := PromptPassphrase("Enter new passphrase (empty for no passphrase): ")
+	if err != nil {
+		return nil, err
+	}
+	again, err := PromptPassphrase("Enter same passphrase again: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(passphrase, again) {
+		return nil, errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// Adds, changes or removes the passphrase of a private key file.
+// The file is replaced in one step, so a failure leaves the old one.
+func ChangePassphrase(key_file string) {
+	key := ReadExistingKey(key_file)
+	passphrase, err := PromptNewPassphrase()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	pem_data, err := MarshalEncryptedPrivateKey(key, passphrase)
+	CheckErrFatal(err)
+	temp_path := key_file + ".tmp"
+	err = os.WriteFile(temp_path, pem_data, 0600)
+	CheckErrFatal(err)
+	err = os.Rename(temp_path, key_file)
+	CheckErrFatal(err)
+	if len(passphrase) == 0 {
+		fmt.Println("Removed the passphrase from", key_file)
+	} else {
+		fmt.Println("Encrypted", key_file, "with the new passphrase")
+	}
+}
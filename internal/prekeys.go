@@ -0,0 +1,468 @@
+/*
+Prekeys give messages forward secrecy.
+
+Each reader keeps a few X25519 prekeys in a file next to its private
+key, and publishes the public half of the newest one to the relay,
+signed with its long-term key. A writer fetches the recipient's
+prekeys, checks the signatures, and wraps the message's AES key for
+each of them using a fresh ephemeral X25519 key (see
+Message.EncryptForPrekeys). Each device has its own prekey, so a
+message is wrapped once per device.
+
+Readers make a new prekey every PREKEY_ROTATION and delete the private
+half once it is PREKEY_RETENTION old. After that, captured messages
+that were wrapped for it can't be decrypted by anyone, even with the
+long-term key. The long-term key still signs messages and prekeys, so
+it only authenticates. Recipients without any prekeys (older clients)
+are still sent messages wrapped with their long-term key, and the
+writer warns that those have no forward secrecy.
+
+This is forward secrecy at the granularity of a weekly prekey only.
+There is no X3DH handshake and no ratchet, so every message wrapped
+for a prekey is exposed if that prekey leaks before it is deleted.
+
+The relay keeps published prekeys in memory. Readers publish theirs
+again whenever they connect, so nothing is lost on a restart.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/curve25519"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// How often a reader makes a new prekey.
+	PREKEY_ROTATION = time.Hour * 24 * 7
+	// Prekeys older than this aren't used by writers or kept by the relay.
+	PREKEY_MAX_AGE = PREKEY_ROTATION * 2
+	// How long a reader keeps the private half of a prekey. This leaves
+	// a week for messages wrapped for it to sit in the mailbox.
+	PREKEY_RETENTION = PREKEY_MAX_AGE + time.Hour*24*7
+	// How often a running reader checks whether it's time to rotate.
+	PREKEY_CHECK_INTERVAL = time.Hour
+	// How long a writer uses the prekeys it fetched for a friend.
+	PREKEY_CACHE_TTL = time.Minute * 5
+	// How many prekeys the relay keeps for each key.
+	MAX_PREKEYS_PER_KEY = 8
+)
+
+// Builds the text that the owner of a prekey signs.
+func prekeySigningText(public_key []byte, created_at int64, algorithm PBSignatureAlgorithm) []byte {
+	text := []byte("peppermint-prekey-v1")
+	text = binary.BigEndian.AppendUint32(text, uint32(algorithm))
+	text = binary.BigEndian.AppendUint64(text, uint64(created_at))
+	return append(text, public_key...)
+}
+
+// Checks that the prekey was signed by the owner and isn't too old to use.
+func VerifyPrekey(owner crypto.PublicKey, prekey *PBPrekey) error {
+	if len(prekey.GetPublicKey()) != curve25519.PointSize {
+		return errors.New("prekey is the wrong size")
+	}
+	created_at := time.Unix(prekey.GetCreatedAt(), 0)
+	if time.Since(created_at) > PREKEY_MAX_AGE {
+		return errors.New("prekey has expired")
+	}
+	if time.Until(created_at) > MESSAGE_CLOCK_SKEW {
+		return errors.New("prekey was created in the future")
+	}
+	signed_text := prekeySigningText(prekey.GetPublicKey(), prekey.GetCreatedAt(), prekey.GetSignatureAlgorithm())
+	if !VerifyText(owner, prekey.GetSignatureAlgorithm(), signed_text, prekey.GetSignature()) {
+		return errors.New("prekey signature does not match its owner")
+	}
+	return nil
+}
+
+// A client's own prekeys, saved to a file.
+type PrekeyStore struct {
+	mutex   sync.Mutex
+	path    string
+	prekeys []*PBPrekeyPrivate
+}
+
+// Opens the store at the given path. The file is created the first
+// time a prekey is made.
+func OpenPrekeyStore(path string) (*PrekeyStore, error) {
+	store := &PrekeyStore{path: path}
+	err := store.load()
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *PrekeyStore) load() error {
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		store.prekeys = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read prekeys... %w", err)
+	}
+	saved := &PBPrekeyStore{}
+	err = proto.Unmarshal(data, saved)
+	if err != nil {
+		return fmt.Errorf("could not parse prekeys in %v... %w", store.path, err)
+	}
+	store.prekeys = saved.Prekeys
+	return nil
+}
+
+// Writes the prekeys to a temporary file and moves it into place.
+func (store *PrekeyStore) save() error {
+	data, err := proto.Marshal(&PBPrekeyStore{Prekeys: store.prekeys})
+	if err != nil {
+		return fmt.Errorf("could not serialize prekeys... %w", err)
+	}
+	temp_path := store.path + ".tmp"
+	err = os.WriteFile(temp_path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("could not write prekeys... %w", err)
+	}
+	return os.Rename(temp_path, store.path)
+}
+
+// Returns the private half of the prekey with the given public key,
+// or nil if we don't have it. Another process may have made a new
+// prekey, so the file is read again before giving up.
+func (store *PrekeyStore) Find(public_key []byte) []byte {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if private_key := store.find(public_key); private_key != nil {
+		return private_key
+	}
+	if store.load() != nil {
+		return nil
+	}
+	return store.find(public_key)
+}
+
+func (store *PrekeyStore) find(public_key []byte) []byte {
+	for _, prekey := range store.prekeys {
+		derived, err := curve25519.X25519(prekey.GetPrivateKey(), curve25519.Basepoint)
+		if err == nil && bytes.Equal(derived, public_key) {
+			return prekey.GetPrivateKey()
+		}
+	}
+	return nil
+}
+
+// Makes a new prekey if the newest one is due for rotation, deletes
+// the ones past retention, and returns the newest one for publishing.
+func (store *PrekeyStore) Refresh(identity Identity) (*PBPrekey, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	// pick up any prekey made by another process first
+	err := store.load()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var kept []*PBPrekeyPrivate
+	for _, prekey := range store.prekeys {
+		if now.Sub(time.Unix(prekey.GetCreatedAt(), 0)) < PREKEY_RETENTION {
+			kept = append(kept, prekey)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].GetCreatedAt() < kept[j].GetCreatedAt() })
+	changed := len(kept) != len(store.prekeys)
+	if len(kept) == 0 || now.Sub(time.Unix(kept[len(kept)-1].GetCreatedAt(), 0)) >= PREKEY_ROTATION {
+		private_key, public_key := GenerateX25519Key()
+		created_at := now.Unix()
+		algorithm := identity.SignatureAlgorithm()
+		signature, err := identity.Sign(prekeySigningText(public_key, created_at, algorithm))
+		if err != nil {
+			return nil, fmt.Errorf("could not sign prekey... %w", err)
+		}
+		kept = append(kept, &PBPrekeyPrivate{
+			PrivateKey:         private_key,
+			CreatedAt:          created_at,
+			Signature:          signature,
+			SignatureAlgorithm: algorithm,
+		})
+		changed = true
+	}
+	store.prekeys = kept
+	if changed {
+		err = store.save()
+		if err != nil {
+			return nil, err
+		}
+	}
+	newest := kept[len(kept)-1]
+	public_key, err := curve25519.X25519(newest.GetPrivateKey(), curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("could not derive prekey... %w", err)
+	}
+	return &PBPrekey{
+		PublicKey:          public_key,
+		CreatedAt:          newest.GetCreatedAt(),
+		Signature:          newest.GetSignature(),
+		SignatureAlgorithm: newest.GetSignatureAlgorithm(),
+	}, nil
+}
+
+// The prekeys the relay holds for each key, newest last.
+type PrekeyDirectory struct {
+	mutex   sync.Mutex
+	prekeys map[string][]*PBPrekey
+}
+
+func NewPrekeyDirectory() *PrekeyDirectory {
+	return &PrekeyDirectory{prekeys: map[string][]*PBPrekey{}}
+}
+
+// Stores a prekey for the owner once its signature checks out.
+// Publishing the same prekey again is harmless.
+func (directory *PrekeyDirectory) Publish(owner_key string, prekey *PBPrekey) error {
+	owner, err := PublicKeyFromString(owner_key)
+	if err != nil {
+		return err
+	}
+	err = VerifyPrekey(owner, prekey)
+	if err != nil {
+		return err
+	}
+	directory.mutex.Lock()
+	defer directory.mutex.Unlock()
+	kept := directory.current(owner_key)
+	for _, existing := range kept {
+		if bytes.Equal(existing.GetPublicKey(), prekey.GetPublicKey()) {
+			return nil
+		}
+	}
+	kept = append(kept, prekey)
+	if len(kept) > MAX_PREKEYS_PER_KEY {
+		kept = kept[len(kept)-MAX_PREKEYS_PER_KEY:]
+	}
+	directory.prekeys[owner_key] = kept
+	return nil
+}
+
+// Returns the prekeys for the key that haven't expired.
+func (directory *PrekeyDirectory) Bundle(owner_key string) *PBPrekeyBundle {
+	directory.mutex.Lock()
+	defer directory.mutex.Unlock()
+	return &PBPrekeyBundle{Prekeys: directory.current(owner_key)}
+}
+
+// Drops expired prekeys for the key. Callers hold the mutex.
+func (directory *PrekeyDirectory) current(owner_key string) []*PBPrekey {
+	var kept []*PBPrekey
+	for _, prekey := range directory.prekeys[owner_key] {
+		if time.Since(time.Unix(prekey.GetCreatedAt(), 0)) < PREKEY_MAX_AGE {
+			kept = append(kept, prekey)
+		}
+	}
+	if len(kept) == 0 {
+		delete(directory.prekeys, owner_key)
+	} else {
+		directory.prekeys[owner_key] = kept
+	}
+	return kept
+}
+
+// A POST publishes a prekey for the sender.
+// A GET returns the prekeys of the key in the target key header,
+// as long as the sender could publish to it.
+func (cs *ChatServer) prekeysHandler(w http.ResponseWriter, r *http.Request) {
+	sender := r.Header.Get(HEADER_PUBLIC_KEY)
+	switch r.Method {
+	case http.MethodPost:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusInternalServerError)
+			return
+		}
+		prekey := &PBPrekey{}
+		err = proto.Unmarshal(body, prekey)
+		if err != nil {
+			http.Error(w, "could not deserialize prekey", http.StatusBadRequest)
+			return
+		}
+		err = cs.prekeys.Publish(sender, prekey)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("rejected prekey... %v", err), http.StatusBadRequest)
+			return
+		}
+	case http.MethodGet:
+		target := r.Header.Get(HEADER_TARGET_PUBLIC_KEY)
+		if !cs.access.CanPublish(sender, target) {
+			http.Error(w, "recipient does not share a group with you", http.StatusForbidden)
+			return
+		}
+		data, err := proto.Marshal(cs.prekeys.Bundle(target))
+		if err != nil {
+			http.Error(w, "could not serialize prekeys", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Implemented by transports that can look up a friend's prekeys.
+type PrekeyTransport interface {
+	// Returns the friend's usable prekeys, or none if they have
+	// none or they couldn't be fetched.
+	Prekeys(*FriendDetail) [][]byte
+}
+
+type cachedPrekeys struct {
+	prekeys    [][]byte
+	fetched_at time.Time
+}
+
+// Returns the friend's prekeys, fetching them at most once every
+// PREKEY_CACHE_TTL. Prekeys that don't verify against the friend's
+// key are dropped.
+func (webt *WEBTransport) Prekeys(friend *FriendDetail) [][]byte {
+	key := PublicKeyToString(friend.public_key)
+	webt.prekey_mutex.Lock()
+	defer webt.prekey_mutex.Unlock()
+	if cached, ok := webt.prekey_cache[key]; ok && time.Since(cached.fetched_at) < PREKEY_CACHE_TTL {
+		return cached.prekeys
+	}
+	bundle, err := webt.fetchPrekeys(key)
+	if err != nil && CheckDebug() {
+		fmt.Fprintln(output, "Could not fetch prekeys for", friend.name, "...", err)
+	}
+	var prekeys [][]byte
+	for _, prekey := range bundle.GetPrekeys() {
+		if VerifyPrekey(friend.public_key, prekey) == nil {
+			prekeys = append(prekeys, prekey.GetPublicKey())
+		}
+	}
+	if webt.prekey_cache == nil {
+		webt.prekey_cache = map[string]cachedPrekeys{}
+	}
+	webt.prekey_cache[key] = cachedPrekeys{prekeys: prekeys, fetched_at: time.Now()}
+	return prekeys
+}
+
+func (webt *WEBTransport) fetchPrekeys(target_key string) (*PBPrekeyBundle, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, webt.host_url+"/prekeys", nil)
+	if err != nil {
+		return nil, fmt.Errorf("problem constructing prekey request... %w", err)
+	}
+	req.Header.Set(HEADER_TARGET_PUBLIC_KEY, target_key)
+	SignRequest(req, webt.identity, nil)
+	resp, err := webt.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("problem performing prekey request... %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read prekeys... %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch prekeys... %s", string(body))
+	}
+	bundle := &PBPrekeyBundle{}
+	err = proto.Unmarshal(body, bundle)
+	if err != nil {
+		return nil, fmt.Errorf("could not deserialize prekeys... %w", err)
+	}
+	return bundle, nil
+}
+
+// Opens our prekey store the first time it's needed.
+// Returns nil when there is no prekey file configured.
+func (webt *WEBTransport) prekeyStore() (*PrekeyStore, error) {
+	webt.prekey_mutex.Lock()
+	defer webt.prekey_mutex.Unlock()
+	if webt.prekey_store != nil || webt.prekey_file == "" {
+		return webt.prekey_store, nil
+	}
+	store, err := OpenPrekeyStore(webt.prekey_file)
+	if err != nil {
+		return nil, err
+	}
+	webt.prekey_store = store
+	return store, nil
+}
+
+// Rotates our prekey if it's time and publishes the newest one.
+func (webt *WEBTransport) publishPrekey() error {
+	store, err := webt.prekeyStore()
+	if err != nil || store == nil {
+		return err
+	}
+	prekey, err := store.Refresh(webt.identity)
+	if err != nil {
+		return err
+	}
+	body, err := proto.Marshal(prekey)
+	if err != nil {
+		return fmt.Errorf("could not serialize prekey... %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webt.host_url+"/prekeys", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("problem constructing prekey request... %w", err)
+	}
+	SignRequest(req, webt.identity, body)
+	resp, err := webt.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("problem publishing prekey... %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		resp_body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unable to publish prekey... %s", string(resp_body))
+	}
+	return nil
+}
+
+// Publishes our prekey, reporting any problem as a connection status.
+func (webt *WEBTransport) refreshPrekey() {
+	err := webt.publishPrekey()
+	if err != nil {
+		PrintConnectionStatus(fmt.Sprintf("could not publish prekey: %v", err))
+	}
+}
+
+// Rotates our prekey on time for as long as the reader runs.
+// The prekey is also published every time the reader connects.
+func (webt *WEBTransport) prekeyLoop() {
+	for {
+		time.Sleep(PREKEY_CHECK_INTERVAL)
+		webt.refreshPrekey()
+	}
+}
+
+// Decrypts a verified message with our prekeys, or with our
+// long-term key if it wasn't wrapped for a prekey. Keys wrapped for
+// an Ed25519 identity's X25519 key are unwrapped by the identity.
+func (webt *WEBTransport) decrypt(message *Message) error {
+	if message.key_algorithm != PBKeyAlgorithm_KEY_X25519_PREKEY {
+		return message.Decrypt(webt.identity)
+	}
+	store, err := webt.prekeyStore()
+	if err != nil {
+		return err
+	}
+	return message.DecryptWithPrekeys(store)
+}
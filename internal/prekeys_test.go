@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrekeyStoreRotation(t *testing.T) {
+	key := GenerateRandomKey()
+	store, err := OpenPrekeyStore(filepath.Join(t.TempDir(), "id_rsa.prekeys"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := store.Refresh(NewKeyIdentity(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyPrekey(&key.PublicKey, first); err != nil {
+		t.Errorf("published prekey does not verify: %v", err)
+	}
+	again, _ := store.Refresh(NewKeyIdentity(key))
+	if string(again.PublicKey) != string(first.PublicKey) {
+		t.Error("prekey was rotated before it was due")
+	}
+	// age the prekey past rotation, then past retention
+	store.prekeys[0].CreatedAt = time.Now().Add(-PREKEY_ROTATION).Unix()
+	store.save()
+	rotated, _ := store.Refresh(NewKeyIdentity(key))
+	if string(rotated.PublicKey) == string(first.PublicKey) {
+		t.Error("prekey was not rotated")
+	}
+	if store.Find(first.PublicKey) == nil {
+		t.Error("the old prekey should be kept until retention")
+	}
+	store.prekeys[0].CreatedAt = time.Now().Add(-PREKEY_RETENTION).Unix()
+	store.save()
+	store.Refresh(NewKeyIdentity(key))
+	if store.Find(first.PublicKey) != nil {
+		t.Error("the old prekey should be deleted after retention")
+	}
+}
+
+// A message wrapped for a prekey can only be read while the prekey is kept.
+func TestPrekeyMessageRoundTrip(t *testing.T) {
+	sender := GenerateRandomKey()
+	recipient := GenerateRandomKey()
+	store, _ := OpenPrekeyStore(filepath.Join(t.TempDir(), "id_rsa.prekeys"))
+	prekey, err := store.Refresh(NewKeyIdentity(recipient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newMessage := func() Message {
+		message := Message{content: []byte("Hello"), public_key: EncodePublicKey(sender)}
+		err := message.EncryptForPrekeys(&recipient.PublicKey, [][]byte{prekey.PublicKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+		message.Sign(NewKeyIdentity(sender))
+		parsed, _ := MessageFromBytes(message.Serialize())
+		return parsed
+	}
+	message := newMessage()
+	if len(message.aes_key) != 0 {
+		t.Error("the AES key should not be wrapped with the long-term key")
+	}
+	if err := message.Verify(&recipient.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	tampered := newMessage()
+	tampered.wrapped_keys[0].ephemeral_key[0] ^= 1
+	if tampered.VerifySignature() {
+		t.Error("signature still verified after the ephemeral key changed")
+	}
+	if err := message.DecryptWithPrekeys(store); err != nil || string(message.content) != "Hello" {
+		t.Fatalf("could not decrypt with the prekey: %v", err)
+	}
+	// without the prekey, the long-term key is no help
+	message = newMessage()
+	if message.Decrypt(NewKeyIdentity(recipient)) == nil {
+		t.Error("decrypted a prekey message with the long-term key")
+	}
+	empty, _ := OpenPrekeyStore(filepath.Join(t.TempDir(), "id_rsa.prekeys"))
+	if message.DecryptWithPrekeys(empty) == nil {
+		t.Error("decrypted a message without its prekey")
+	}
+}
+
+func TestPublishAndFetchPrekeys(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	alice := GenerateRandomKey()
+	bob := GenerateRandomKey()
+	bob_webt := &WEBTransport{host_url: server.URL, identity: NewKeyIdentity(bob), prekey_file: filepath.Join(t.TempDir(), "id_rsa.prekeys")}
+	if err := bob_webt.publishPrekey(); err != nil {
+		t.Fatal(err)
+	}
+	// publishing on every connect doesn't pile up copies
+	if err := bob_webt.publishPrekey(); err != nil {
+		t.Fatal(err)
+	}
+	alice_webt := &WEBTransport{host_url: server.URL, identity: NewKeyIdentity(alice)}
+	prekeys := alice_webt.Prekeys(&FriendDetail{public_key: &bob.PublicKey, name: "bob"})
+	if len(prekeys) != 1 {
+		t.Fatalf("expected bob's prekey, got %v", len(prekeys))
+	}
+	// a prekey signed by someone else is rejected by the relay
+	_, public_key := GenerateX25519Key()
+	created_at := time.Now().Unix()
+	algorithm := PBSignatureAlgorithm_SIGNATURE_RSA_PSS
+	signature, _ := SignWithAlgorithm(alice, algorithm, prekeySigningText(public_key, created_at, algorithm))
+	forged := &PBPrekey{PublicKey: public_key, CreatedAt: created_at, Signature: signature, SignatureAlgorithm: algorithm}
+	if err := cs.prekeys.Publish(PublicKeyToString(&bob.PublicKey), forged); err == nil {
+		t.Error("the relay accepted a prekey with the wrong signature")
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Who is online, as far as the relay can tell.
+
+A key is online while it has a /subscribe connection open, which is
+what a reader, chat or the TUI keeps while it runs. Clients POST the
+keys they want to know about to /presence, and the relay answers with
+those that are connected. Keys the sender couldn't publish to are
+never reported, so this tells nobody more than they could learn by
+messaging.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// How often the TUI asks who is online.
+const PRESENCE_INTERVAL = time.Second * 15
+
+var errPresenceNotSupported = errors.New("server does not report who is online")
+
+// Implemented by transports that can ask the relay who is online.
+type PresenceTransport interface {
+	// Returns the keys, from those given, with a reader connected.
+	Presence(keys []string) (map[string]bool, error)
+}
+
+// Whether anyone is subscribed with the key.
+func (cs *ChatServer) isOnline(pub_key string) bool {
+	cs.subscriber_mutex.Lock()
+	defer cs.subscriber_mutex.Unlock()
+	return len(cs.subscribers[pub_key]) > 0
+}
+
+// A POST with a PBPresence is answered with the keys in it that
+// are online and that the sender shares a group with.
+func (cs *ChatServer) presenceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusInternalServerError)
+		return
+	}
+	request := &PBPresence{}
+	err = proto.Unmarshal(body, request)
+	if err != nil {
+		http.Error(w, "could not deserialize presence request", http.StatusBadRequest)
+		return
+	}
+	sender := r.Header.Get(HEADER_PUBLIC_KEY)
+	online := &PBPresence{}
+	for _, key := range request.GetKeys() {
+		if cs.access.CanPublish(sender, key) && cs.isOnline(key) {
+			online.Keys = append(online.Keys, key)
+		}
+	}
+	data, err := proto.Marshal(online)
+	if err != nil {
+		http.Error(w, "could not serialize presence", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(data)
+}
+
+func (webt *WEBTransport) Presence(keys []string) (map[string]bool, error) {
+	body, err := proto.Marshal(&PBPresence{Keys: keys})
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize presence request... %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webt.host_url+"/presence", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("problem constructing presence request... %w", err)
+	}
+	SignRequest(req, webt.identity, body)
+	resp, err := webt.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("problem performing presence request... %w", err)
+	}
+	defer resp.Body.Close()
+	resp_body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errPresenceNotSupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to ask who is online... %s", string(resp_body))
+	}
+	online := &PBPresence{}
+	err = proto.Unmarshal(resp_body, online)
+	if err != nil {
+		return nil, fmt.Errorf("could not deserialize presence... %w", err)
+	}
+	online_keys := map[string]bool{}
+	for _, key := range online.GetKeys() {
+		online_keys[key] = true
+	}
+	return online_keys, nil
+}
@@ -0,0 +1,184 @@
+/*
+End to end delivery and read receipts.
+
+A reader that decrypts a message sends a "delivered" receipt back to
+its writer, and a "read" receipt once the message has been shown if
+read_receipts is set in the group's config. Receipts are ordinary
+encrypted messages whose payload refers to the original message ID,
+so the server can't tell them apart from anything else.
+
+The writer keeps track of the messages it has sent and prints each
+recipient's status again whenever a receipt moves it along.
+*/
+
+package internal
+
+import (
+	"crypto"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How many sent messages the writer keeps track of.
+const TRACKED_MESSAGE_LIMIT = 100
+
+// Only this much of a message is repeated in its status line.
+const STATUS_PREVIEW_LENGTH = 20
+
+type DeliveryStatus int
+
+const (
+	STATUS_FAILED DeliveryStatus = iota
+	STATUS_SENT
+	STATUS_DELIVERED
+	STATUS_READ
+)
+
+func (status DeliveryStatus) String() string {
+	switch status {
+	case STATUS_SENT:
+		return MEDIUM_CHECK_MARK + " sent"
+	case STATUS_DELIVERED:
+		return MEDIUM_CHECK_MARK + MEDIUM_CHECK_MARK + " delivered"
+	case STATUS_READ:
+		return CHECK_MARK + " read"
+	default:
+		return X_MARK + " failed"
+	}
+}
+
+func statusFromReceipt(status PBReceiptStatus) DeliveryStatus {
+	if status == PBReceiptStatus_RECEIPT_READ {
+		return STATUS_READ
+	}
+	return STATUS_DELIVERED
+}
+
+type trackedMessage struct {
+	preview string
+	// recipient keys, in the order they were sent to
+	recipients []string
+	names      map[string]string
+	statuses   map[string]DeliveryStatus
+}
+
+// Remembers the status of each recipient of recently sent messages.
+type DeliveryTracker struct {
+	mutex    sync.Mutex
+	self_key string
+	messages map[string]*trackedMessage
+	order    []string
+}
+
+func NewDeliveryTracker(self_key crypto.PublicKey) *DeliveryTracker {
+	return &DeliveryTracker{
+		self_key: PublicKeyToString(self_key),
+		messages: map[string]*trackedMessage{},
+	}
+}
+
+// Records a message that was just sent, along with the error from
+// sending to each recipient, and prints its status.
+// The copy sent to yourself isn't tracked.
+func (tracker *DeliveryTracker) Sent(message_id string, text string, batch []OutboundMessage, errs []error) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracked := &trackedMessage{
+		preview:  previewText(text),
+		names:    map[string]string{},
+		statuses: map[string]DeliveryStatus{},
+	}
+	for i, outbound := range batch {
+		key := PublicKeyToString(outbound.friend.public_key)
+		if key == tracker.self_key {
+			continue
+		}
+		tracked.recipients = append(tracked.recipients, key)
+		tracked.names[key] = outbound.friend.name
+		tracked.statuses[key] = STATUS_SENT
+		if errs[i] != nil {
+			tracked.statuses[key] = STATUS_FAILED
+		}
+	}
+	if len(tracked.recipients) == 0 {
+		return
+	}
+	tracker.messages[message_id] = tracked
+	tracker.order = append(tracker.order, message_id)
+	if len(tracker.order) > TRACKED_MESSAGE_LIMIT {
+		delete(tracker.messages, tracker.order[0])
+		tracker.order = tracker.order[1:]
+	}
+	tracked.printStatus(message_id)
+}
+
+// Applies a receipt from the given key and prints the new status.
+// Receipts for unknown messages, or that don't move a recipient
+// along, are ignored.
+func (tracker *DeliveryTracker) Update(message_id string, sender_key string, status DeliveryStatus) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracked, ok := tracker.messages[message_id]
+	if !ok {
+		return
+	}
+	current, ok := tracked.statuses[sender_key]
+	if !ok || current >= status {
+		return
+	}
+	tracked.statuses[sender_key] = status
+	tracked.printStatus(message_id)
+}
+
+func (tracked *trackedMessage) printStatus(message_id string) {
+	if display != nil {
+		display.ShowDeliveryStatus(message_id, tracked.statusSummary())
+		return
+	}
+	fmt.Fprintln(output, tracked.statusLine())
+}
+
+func (tracked *trackedMessage) statusLine() string {
+	return fmt.Sprintf("%q %v", tracked.preview, tracked.statusSummary())
+}
+
+// Each recipient's name and status.
+func (tracked *trackedMessage) statusSummary() string {
+	parts := make([]string, len(tracked.recipients))
+	for i, key := range tracked.recipients {
+		parts[i] = fmt.Sprintf("%v %v", tracked.names[key], tracked.statuses[key])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func previewText(text string) string {
+	first_line := strings.SplitN(text, "\n", 2)[0]
+	runes := []rune(first_line)
+	if len(runes) > STATUS_PREVIEW_LENGTH || first_line != text {
+		if len(runes) > STATUS_PREVIEW_LENGTH {
+			runes = runes[:STATUS_PREVIEW_LENGTH]
+		}
+		return string(runes) + "\u2026"
+	}
+	return first_line
+}
+
+// Sends a receipt for the message back to the friend who wrote it.
+// This runs in its own goroutine so the reader isn't held up.
+func (webt *WEBTransport) sendReceipt(friend FriendDetail, group_id string, message_id string, status PBReceiptStatus) {
+	payload := Payload{
+		message_id: RandomID(),
+		timestamp:  time.Now().UnixMilli(),
+		group_id:   group_id,
+		receipt:    &Receipt{message_id: message_id, status: status},
+	}
+	content, err := SealPayload(payload, webt.identity, friend.public_key, webt.Prekeys(&friend))
+	if err == nil {
+		err = webt.Writer(&friend, content)
+	}
+	if err != nil && CheckDebug() {
+		fmt.Fprintln(output, "Could not send receipt to", friend.name, "...", err)
+	}
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliveryTracker(t *testing.T) {
+	var printed bytes.Buffer
+	SetOutput(&printed)
+	defer SetOutput(os.Stdout)
+	self := GenerateRandomKey()
+	bill := &FriendDetail{public_key: &GenerateRandomKey().PublicKey, name: "Bill"}
+	andy := &FriendDetail{public_key: &GenerateRandomKey().PublicKey, name: "Andy"}
+	yourself := &FriendDetail{public_key: &self.PublicKey, name: "Yourself"}
+	tracker := NewDeliveryTracker(&self.PublicKey)
+	batch := []OutboundMessage{{friend: bill}, {friend: andy}, {friend: yourself}}
+	tracker.Sent("m1", "Hello there", batch, []error{nil, errors.New("nope"), nil})
+	if !strings.Contains(printed.String(), "Bill "+STATUS_SENT.String()) ||
+		!strings.Contains(printed.String(), "Andy "+STATUS_FAILED.String()) {
+		t.Errorf("unexpected status line: %q", printed.String())
+	}
+	if strings.Contains(printed.String(), "Yourself") {
+		t.Error("the copy sent to yourself should not be tracked")
+	}
+	bill_key := PublicKeyToString(bill.public_key)
+	printed.Reset()
+	tracker.Update("m1", bill_key, STATUS_READ)
+	if !strings.Contains(printed.String(), "Bill "+STATUS_READ.String()) {
+		t.Errorf("status was not updated: %q", printed.String())
+	}
+	// a late delivered receipt doesn't move the status backwards
+	printed.Reset()
+	tracker.Update("m1", bill_key, STATUS_DELIVERED)
+	tracker.Update("unknown", bill_key, STATUS_DELIVERED)
+	if printed.Len() != 0 {
+		t.Errorf("nothing should be printed, got %q", printed.String())
+	}
+}
+
+// A writer that only watches receipts handles receipts from friends
+// and leaves every other message for the reader.
+func TestHandleReceipt(t *testing.T) {
+	var printed bytes.Buffer
+	SetOutput(&printed)
+	defer SetOutput(os.Stdout)
+	self := GenerateRandomKey()
+	bill_key := GenerateRandomKey()
+	bill := &FriendDetail{public_key: &bill_key.PublicKey, name: "Bill"}
+	tracker := NewDeliveryTracker(&self.PublicKey)
+	tracker.Sent("m1", "Hello", []OutboundMessage{{friend: bill}}, []error{nil})
+	webt := &WEBTransport{identity: NewKeyIdentity(self), tracker: tracker, receipts_only: true}
+
+	message := Payload{message_id: "m2", timestamp: time.Now().UnixMilli(), group_id: "friends", text: "Hi"}
+	sealed, err := SealPayload(message, NewKeyIdentity(bill_key), &self.PublicKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if webt.handleMessage(sealed, "", nil) {
+		t.Error("a message was handled by a receipts only reader")
+	}
+	receipt := Payload{
+		message_id: "r1",
+		timestamp:  time.Now().UnixMilli(),
+		group_id:   "friends",
+		receipt:    &Receipt{message_id: "m1", status: PBReceiptStatus_RECEIPT_DELIVERED},
+	}
+	sealed, err = SealPayload(receipt, NewKeyIdentity(bill_key), &self.PublicKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !webt.handleMessage(sealed, "", nil) {
+		t.Fatal("the receipt was not handled")
+	}
+	status := tracker.messages["m1"].statuses[PublicKeyToString(bill.public_key)]
+	if status != STATUS_DELIVERED {
+		t.Errorf("expected delivered, got %v", status)
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Replacing your key.
+
+'peppermint key rotate' makes a new key of the same type, and sends a
+rotation statement to everyone in every group in the config. The
+statement names the old and new keys and is signed by both: the old
+key's signature shows it comes from whoever held the old key, and the
+new key's shows the sender holds the new one.
+
+A reader that gets a valid statement from a friend swaps the key
+wherever it appears in the config, and warns that the key changed. The
+new key hasn't been verified, so the friend shows as unverified until
+safety numbers are compared again.
+
+The old key is kept next to the new one with .old on the end, along
+with its prekeys, in case there are messages for it still to read.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Builds the bytes that both keys sign for a rotation statement.
+func rotationSigningText(rotation *PBRotation) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("peppermint-rotation-v1")
+	for _, field := range [][]byte{
+		rotation.GetOldPublicKey(),
+		rotation.GetNewPublicKey(),
+		binary.BigEndian.AppendUint64(nil, uint64(rotation.GetTimestamp())),
+		binary.BigEndian.AppendUint32(nil, uint32(rotation.GetOldSignatureAlgorithm())),
+		binary.BigEndian.AppendUint32(nil, uint32(rotation.GetNewSignatureAlgorithm())),
+	} {
+		buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+		buffer.Write(field)
+	}
+	return buffer.Bytes()
+}
+
+// Makes a statement that the old identity is replaced by the new one,
+// signed by both.
+func CreateRotation(old_identity Identity, new_identity Identity) (*PBRotation, error) {
+	rotation := &PBRotation{
+		OldPublicKey:          PublicKeyToBytes(old_identity.Public()),
+		NewPublicKey:          PublicKeyToBytes(new_identity.Public()),
+		Timestamp:             time.Now().UnixMilli(),
+		OldSignatureAlgorithm: old_identity.SignatureAlgorithm(),
+		NewSignatureAlgorithm: new_identity.SignatureAlgorithm(),
+	}
+	text := rotationSigningText(rotation)
+	var err error
+	rotation.OldSignature, err = old_identity.Sign(text)
+	if err != nil {
+		return nil, fmt.Errorf("could not sign with the old key... %w", err)
+	}
+	rotation.NewSignature, err = new_identity.Sign(text)
+	if err != nil {
+		return nil, fmt.Errorf("could not sign with the new key... %w", err)
+	}
+	return rotation, nil
+}
+
+// Checks both signatures on a rotation statement, and returns
+// the old and new keys.
+func VerifyRotation(rotation *PBRotation) (crypto.PublicKey, crypto.PublicKey, error) {
+	old_key, err := BytesToPublicKey(rotation.GetOldPublicKey())
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad old key... %w", err)
+	}
+	new_key, err := BytesToPublicKey(rotation.GetNewPublicKey())
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad new key... %w", err)
+	}
+	if bytes.Equal(rotation.GetOldPublicKey(), rotation.GetNewPublicKey()) {
+		return nil, nil, errors.New("the old and new keys are the same")
+	}
+	text := rotationSigningText(rotation)
+	if !VerifyText(old_key, rotation.GetOldSignatureAlgorithm(), text, rotation.GetOldSignature()) {
+		return nil, nil, errors.New("signature does not match the old key")
+	}
+	if !VerifyText(new_key, rotation.GetNewSignatureAlgorithm(), text, rotation.GetNewSignature()) {
+		return nil, nil, errors.New("signature does not match the new key")
+	}
+	return old_key, new_key, nil
+}
+
+var config_key_block = regexp.MustCompile(`(?s)'''\n?(.*?)'''`)
+
+// Swaps the old key for the new one everywhere it appears in the config
+// as a PEM block in triple quotes. The file is replaced in one step.
+// Returns how many were swapped.
+func replaceKeyInConfig(config_file string, old_key crypto.PublicKey, new_key crypto.PublicKey) (int, error) {
+	contents, err := os.ReadFile(config_file)
+	if err != nil {
+		return 0, fmt.Errorf("could not read config... %w", err)
+	}
+	old_bytes := PublicKeyToBytes(old_key)
+	replaced := 0
+	contents = config_key_block.ReplaceAllFunc(contents, func(block []byte) []byte {
+		pub_key, err := ParsePublicKey(config_key_block.FindSubmatch(block)[1])
+		if err != nil || !bytes.Equal(PublicKeyToBytes(pub_key), old_bytes) {
+			return block
+		}
+		replaced++
+		return append(append([]byte("'''\n"), PublicKeyToPEM(new_key)...), "'''"...)
+	})
+	if replaced == 0 {
+		return 0, nil
+	}
+	info, err := os.Stat(config_file)
+	if err != nil {
+		return 0, fmt.Errorf("could not read config... %w", err)
+	}
+	temp_path := config_file + ".tmp"
+	err = os.WriteFile(temp_path, contents, info.Mode().Perm())
+	if err != nil {
+		return 0, fmt.Errorf("could not update config... %w", err)
+	}
+	return replaced, os.Rename(temp_path, config_file)
+}
+
+// Replaces the private key, for 'peppermint key rotate'. The statement
+// goes out before anything on disk changes, and if nobody could be sent
+// it the old key is kept.
+func RotateKey() {
+	ParseConfig()
+	if viper.GetString("ssh_agent_key") != "" {
+		fmt.Println("Keys in ssh-agent can't be rotated by peppermint, use private_key_file for the new key")
+		os.Exit(1)
+	}
+	key_file := viper.GetString("private_key_file")
+	if key_file == "" {
+		fmt.Println("No private_key_file in the config")
+		os.Exit(1)
+	}
+	old_key := ReadExistingKey(key_file)
+	key_type, err := KeyType(old_key.Public())
+	CheckErrFatal(err)
+	new_key, err := GenerateKey(key_type)
+	CheckErrFatal(err)
+	var passphrase []byte
+	if HasTerminal() {
+		passphrase, err = PromptNewPassphrase()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	old_identity := NewKeyIdentity(old_key)
+	rotation, err := CreateRotation(old_identity, NewKeyIdentity(new_key))
+	CheckErrFatal(err)
+	sent, failed := announceRotation(old_identity, rotation, parseOtherGroups(""))
+	if sent == 0 {
+		fmt.Println("Nobody was sent the new key, so the old key was kept")
+		os.Exit(1)
+	}
+
+	pemData, err := MarshalEncryptedPrivateKey(new_key, passphrase)
+	CheckErrFatal(err)
+	err = os.WriteFile(key_file+".tmp", pemData, 0600)
+	CheckErrFatal(err)
+	// the old key keeps its prekeys, since they are named after the key file
+	for _, suffix := range []string{"", ".pub", ".prekeys"} {
+		err = os.Rename(key_file+suffix, key_file+".old"+suffix)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			CheckErrFatal(err)
+		}
+	}
+	err = os.Rename(key_file+".tmp", key_file)
+	CheckErrFatal(err)
+	WritePublicKey(new_key, key_file+".pub")
+	fmt.Println("Wrote the new key to", key_file, "and kept the old one in", key_file+".old")
+	fmt.Println("New fingerprint:", ShortFingerprint(new_key.Public()))
+	if failed > 0 {
+		fmt.Println("Send your new public key to whoever missed the change, it's in", key_file+".pub")
+	}
+	fmt.Println("Relays with access groups need the new key added before you can connect.")
+}
+
+// Sends the rotation statement to every user in every group, signed
+// with the old key. Returns how many were and weren't sent.
+func announceRotation(old_identity Identity, rotation *PBRotation, groups []MessangerConfig) (int, int) {
+	sent, failed := 0, 0
+	for _, group := range groups {
+		if group.URL == "" {
+			continue
+		}
+		webt := &WEBTransport{
+			host_url:    group.URL,
+			identity:    old_identity,
+			http_client: NewHTTPClient(group.TLSFingerprint),
+		}
+		for _, user := range group.Users {
+			friend := FriendDetail{name: fmt.Sprintf("%v in %v", user.Name, group.Name)}
+			pub_key, err := ParsePublicKey([]byte(user.Key))
+			if err == nil {
+				friend.public_key = pub_key
+				payload := Payload{
+					message_id: RandomID(),
+					timestamp:  rotation.GetTimestamp(),
+					group_id:   group.GroupID,
+					rotation:   rotation,
+				}
+				var content []byte
+				content, err = SealPayload(payload, old_identity, pub_key, webt.Prekeys(&friend))
+				if err == nil {
+					err = webt.Writer(&friend, content)
+				}
+			}
+			reportDelivery(&friend, err)
+			if err != nil {
+				failed++
+			} else {
+				sent++
+			}
+		}
+	}
+	return sent, failed
+}
+
+// Swaps a friend's key for the new one in a rotation statement they
+// sent, in every group and in the config. Statements from keys that
+// aren't anyone's, or that were already applied, are ignored.
+func (webt *WEBTransport) handleRotation(rotation *PBRotation, sender crypto.PublicKey, friend_map FriendDetailMap) {
+	old_key, new_key, err := VerifyRotation(rotation)
+	if err != nil {
+		fmt.Fprintf(output, "%v Rejected a key change: %v\n", X_MARK, err)
+		return
+	}
+	if !bytes.Equal(PublicKeyToBytes(sender), rotation.GetOldPublicKey()) {
+		fmt.Fprintf(output, "%v Rejected a key change sent by a different key\n", X_MARK)
+		return
+	}
+	friend_maps := []FriendDetailMap{friend_map}
+	for _, group := range webt.other_groups {
+		friend_maps = append(friend_maps, group.friends)
+	}
+	old_string := PublicKeyToString(old_key)
+	name := ""
+	for _, friends := range friend_maps {
+		friend, ok := friends[old_string]
+		if !ok {
+			continue
+		}
+		name = friend.name
+		delete(friends, old_string)
+		friend.public_key = new_key
+		friend.verified = false
+		friends[PublicKeyToString(new_key)] = friend
+	}
+	if name == "" {
+		return
+	}
+	fmt.Fprintf(output, "%v %v changed their key. New fingerprint: %v\n", X_MARK, name, ShortFingerprint(new_key))
+	fmt.Fprintf(output, "Compare safety numbers again with 'peppermint verify %v', and restart any writers\n", name)
+	if webt.config_file == "" {
+		return
+	}
+	replaced, err := replaceKeyInConfig(webt.config_file, old_key, new_key)
+	if err != nil {
+		fmt.Fprintln(output, "Could not update", name, "in the config...", err)
+	} else if replaced == 0 {
+		fmt.Fprintln(output, "Could not find", name+"'s old key in the config, update it by hand")
+	}
+}
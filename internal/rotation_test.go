@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotation(t *testing.T) {
+	old_identity := NewKeyIdentity(GenerateRandomKey())
+	new_key, _ := GenerateKey(KEY_TYPE_ED25519)
+	rotation, err := CreateRotation(old_identity, NewKeyIdentity(new_key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := VerifyRotation(rotation); err != nil {
+		t.Fatal(err)
+	}
+	// swapping in someone else's key breaks both signatures
+	other_key, _ := GenerateKey(KEY_TYPE_ED25519)
+	rotation.NewPublicKey = PublicKeyToBytes(other_key.Public())
+	if _, _, err := VerifyRotation(rotation); err == nil {
+		t.Error("a statement for a different new key was accepted")
+	}
+}
+
+// A friend's reader swaps the key in memory and in the config.
+func TestHandleRotation(t *testing.T) {
+	var printed bytes.Buffer
+	SetOutput(&printed)
+	defer SetOutput(os.Stdout)
+	alice := NewKeyIdentity(GenerateRandomKey())
+	bill := NewKeyIdentity(GenerateRandomKey())
+	new_bill_key, _ := GenerateKey(KEY_TYPE_ED25519)
+	config_file := filepath.Join(t.TempDir(), "config.toml")
+	config_text := "# my friends\n[friends]\nurl = \"https://relay.example.com\"\n" +
+		userConfigText("friends", "Bill", bill.Public()) +
+		"\n[work]\nurl = \"https://relay.example.com\"\n" +
+		userConfigText("work", "Bill", bill.Public())
+	os.WriteFile(config_file, []byte(config_text), 0600)
+	friend_map := FriendDetailMap{PublicKeyToString(bill.Public()): {public_key: bill.Public(), name: "Bill", verified: true}}
+	work_friends := FriendDetailMap{PublicKeyToString(bill.Public()): {public_key: bill.Public(), name: "Bill"}}
+	webt := &WEBTransport{
+		identity:     alice,
+		group_id:     "friends",
+		other_groups: map[string]GroupDetail{"work": {name: "work", friends: work_friends}},
+		config_file:  config_file,
+	}
+
+	send := func(sender Identity, rotation *PBRotation) {
+		payload := Payload{
+			message_id: RandomID(),
+			timestamp:  time.Now().UnixMilli(),
+			group_id:   "friends",
+			rotation:   rotation,
+		}
+		sealed, err := SealPayload(payload, sender, alice.Public(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		webt.handleMessage(sealed, PublicKeyToString(alice.Public()), friend_map)
+	}
+	// someone else can't send Bill's statement
+	rotation, _ := CreateRotation(bill, NewKeyIdentity(new_bill_key))
+	send(NewKeyIdentity(GenerateRandomKey()), rotation)
+	if _, ok := friend_map[PublicKeyToString(bill.Public())]; !ok {
+		t.Fatal("a statement sent by another key was applied")
+	}
+	send(bill, rotation)
+	new_key_string := PublicKeyToString(new_bill_key.Public())
+	friend, ok := friend_map[new_key_string]
+	if !ok || friend.name != "Bill" || friend.verified {
+		t.Fatalf("Bill's key was not replaced: %+v", friend_map)
+	}
+	if _, ok := work_friends[new_key_string]; !ok {
+		t.Error("Bill's key was not replaced in the other group")
+	}
+	contents, _ := os.ReadFile(config_file)
+	if strings.Count(string(contents), string(PublicKeyToPEM(new_bill_key.Public()))) != 2 || !strings.HasPrefix(string(contents), "# my friends\n") {
+		t.Errorf("config was not updated:\n%s", contents)
+	}
+}
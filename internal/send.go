@@ -0,0 +1,81 @@
+/*
+Sending without a prompt, for scripts, cron jobs and pipes.
+
+'peppermint send' publishes the text it's given, or reads it from
+stdin when it isn't a terminal: each line is its own message, or the
+whole stream is one message with --whole. 'peppermint write' does the
+same with each line when its stdin is piped in.
+
+The exit code says whether everyone got everything, so scripts can
+tell when to retry:
+
+	0  every message was sent to every recipient
+	1  nothing could be sent, e.g. stdin couldn't be read
+	2  at least one recipient couldn't be sent at least one message
+*/
+
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const SEND_FAILED_EXIT_CODE = 2
+
+// Sends the text to the group, or stdin if there is no text,
+// and exits with a code saying whether it all went through.
+// With quiet set, only the recipients that couldn't be sent to
+// are printed.
+func SendToGroup(config *MessangerConfig, text string, whole bool, quiet bool) {
+	messanger := ConfigureMessanger(config)
+	messanger.quiet = quiet
+	messanger.OutboundConnect()
+	if text != "" {
+		messanger.Publish(text)
+	} else if HasTerminal() {
+		fmt.Println("Nothing to send, pass the message as an argument or pipe it in")
+		os.Exit(1)
+	} else if err := messanger.SendInput(os.Stdin, whole); err != nil {
+		fmt.Println("Could not read the message...", err)
+		os.Exit(1)
+	}
+	if messanger.failed_sends > 0 {
+		os.Exit(SEND_FAILED_EXIT_CODE)
+	}
+}
+
+// Publishes each line read from the reader, skipping blank ones.
+// With whole set, everything read is published as one message.
+func (ppmt *Messanger) SendInput(reader io.Reader, whole bool) error {
+	if whole {
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		text := strings.TrimRight(string(content), "\r\n")
+		if text != "" {
+			ppmt.Publish(text)
+		}
+		return nil
+	}
+	// lines are read whole, however long, and left for the relay to refuse
+	buffered := bufio.NewReader(reader)
+	for {
+		line, err := buffered.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			ppmt.Publish(line)
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
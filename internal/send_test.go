@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Piped input is sent a line at a time, or all at once with whole
+// set, and recipients that couldn't be sent to are counted.
+func TestSendInput(t *testing.T) {
+	var printed bytes.Buffer
+	SetOutput(&printed)
+	defer SetOutput(os.Stdout)
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	cs.max_envelope_size = 4096
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	alice := NewKeyIdentity(GenerateRandomKey())
+	messanger := &Messanger{
+		recipients: []FriendDetail{
+			{public_key: &GenerateRandomKey().PublicKey, name: "Bill"},
+			{public_key: &GenerateRandomKey().PublicKey, name: "Andy"},
+		},
+		wait_group:  &sync.WaitGroup{},
+		identity:    alice,
+		transport:   &WEBTransport{host_url: server.URL, identity: alice},
+		write_mutex: &sync.Mutex{},
+		group_id:    "friends",
+		tracker:     NewDeliveryTracker(alice.Public()),
+		quiet:       true,
+	}
+	before := messanger.sequence
+	err := messanger.SendInput(strings.NewReader("hello\n\r\nthere"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messanger.sequence-before != 2 || messanger.failed_sends != 0 {
+		t.Errorf("expected two messages to be sent, got %v with %v failures", messanger.sequence-before, messanger.failed_sends)
+	}
+	if printed.Len() != 0 {
+		t.Errorf("nothing should be printed when quiet, got %q", printed.String())
+	}
+
+	// too big as one message, though each line would fit
+	lines := strings.Repeat(strings.Repeat("a", 1000)+"\n", 5)
+	err = messanger.SendInput(strings.NewReader(lines), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messanger.failed_sends != 2 {
+		t.Errorf("expected both recipients to fail, got %v", messanger.failed_sends)
+	}
+	if !strings.Contains(printed.String(), "Could not send message to Bill") {
+		t.Errorf("failures should still be printed when quiet, got %q", printed.String())
+	}
+}
+
+// A recipient the message can't be encrypted for is counted as failed,
+// and everyone else still gets it.
+func TestSendToUnencryptableRecipient(t *testing.T) {
+	var printed bytes.Buffer
+	SetOutput(&printed)
+	defer SetOutput(os.Stdout)
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	alice := NewKeyIdentity(GenerateRandomKey())
+	bill := GenerateRandomKey()
+	ecdsa_key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	messanger := &Messanger{
+		recipients: []FriendDetail{
+			{public_key: &bill.PublicKey, name: "Bill"},
+			{public_key: &ecdsa_key.PublicKey, name: "Andy"},
+		},
+		wait_group:  &sync.WaitGroup{},
+		identity:    alice,
+		transport:   &WEBTransport{host_url: server.URL, identity: alice},
+		write_mutex: &sync.Mutex{},
+		group_id:    "friends",
+		quiet:       true,
+	}
+	messanger.Publish("hello")
+	if messanger.failed_sends != 1 {
+		t.Errorf("expected one recipient to fail, got %v", messanger.failed_sends)
+	}
+	if !strings.Contains(printed.String(), "Could not send message to Andy") {
+		t.Errorf("the failure should be printed, got %q", printed.String())
+	}
+	if pending, _ := cs.mailboxes.Pending(PublicKeyToString(&bill.PublicKey), "", 0); len(pending) != 1 {
+		t.Errorf("Bill should still get the message, got %v", pending)
+	}
+}
+
+// Recipients without prekeys get a long-term-key wrap, and the user is
+// warned about it once for each of them, unless quiet.
+func TestWarnAboutMissingPrekeys(t *testing.T) {
+	var printed bytes.Buffer
+	SetOutput(&printed)
+	defer SetOutput(os.Stdout)
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	alice := NewKeyIdentity(GenerateRandomKey())
+	messanger := &Messanger{
+		recipients: []FriendDetail{
+			{public_key: &GenerateRandomKey().PublicKey, name: "Bill"},
+			{public_key: alice.Public(), name: "me"},
+		},
+		wait_group:  &sync.WaitGroup{},
+		identity:    alice,
+		transport:   &WEBTransport{host_url: server.URL, identity: alice},
+		write_mutex: &sync.Mutex{},
+		group_id:    "friends",
+	}
+	messanger.Publish("hello")
+	messanger.Publish("again")
+	if count := strings.Count(printed.String(), "Bill has no prekeys"); count != 1 {
+		t.Errorf("expected one warning about Bill, got %v in %q", count, printed.String())
+	}
+	if strings.Contains(printed.String(), "me has no prekeys") {
+		t.Errorf("there should be no warning about our own key, got %q", printed.String())
+	}
+}
@@ -0,0 +1,320 @@
+/*
+Durable mailbox storage for the relay server.
+
+FileMailboxStore keeps the working set in a MemoryMailboxStore and
+writes every change to an append-only log on disk. Each record in the
+log is a PBLogRecord prefixed by its length as a uvarint.
+On startup the log is replayed to rebuild the mailboxes, so queued
+envelopes, what each device has acked and per-key counters survive
+a restart.
+
+Compact rewrites the log so that it only holds the envelopes that
+are still waiting to be delivered, which keeps it from growing forever.
+*/
+
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const DEFAULT_COMPACTION_INTERVAL = time.Hour
+
+type FileMailboxStore struct {
+	memory *MemoryMailboxStore
+	// serializes writes to the log so records land in the same
+	// order the memory store applied them.
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+}
+
+// Opens the log at path, creating it if needed, and replays it.
+func OpenFileMailboxStore(path string, ttl time.Duration, quota int) (*FileMailboxStore, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("could not create storage directory... %w", err)
+	}
+	store := &FileMailboxStore{
+		memory: NewMemoryMailboxStore(ttl, quota),
+		path:   path,
+	}
+	err = store.replay()
+	if err != nil {
+		return nil, err
+	}
+	store.file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open storage file... %w", err)
+	}
+	return store, nil
+}
+
+// Reads every record in the log back into the memory store.
+// A record cut short by a crash is dropped along with anything after it.
+func (store *FileMailboxStore) replay() error {
+	file, err := os.Open(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not open storage file... %w", err)
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var good_offset int64
+	for {
+		record, size, err := readLogRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			fmt.Printf("Discarding damaged storage log after byte %v: %v\n", good_offset, err)
+			return os.Truncate(store.path, good_offset)
+		}
+		good_offset += size
+		store.apply(record)
+	}
+}
+
+func (store *FileMailboxStore) apply(record *PBLogRecord) {
+	switch {
+	case record.GetEnqueue() != nil:
+		enqueue := record.GetEnqueue()
+		envelope := Envelope{
+			id:         enqueue.GetId(),
+			payload:    enqueue.GetPayload(),
+			expires_at: time.Unix(enqueue.GetExpiresAt(), 0),
+		}
+		if len(enqueue.GetAckedBy()) > 0 {
+			envelope.acked_by = map[string]bool{}
+			for _, device_id := range enqueue.GetAckedBy() {
+				envelope.acked_by[device_id] = true
+			}
+		}
+		store.memory.restore(enqueue.GetPublicKey(), envelope)
+	case record.GetAck() != nil:
+		ack := record.GetAck()
+		// acks from before devices were tracked have no time
+		acked_at := time.Now()
+		if ack.GetAckedAt() > 0 {
+			acked_at = time.Unix(ack.GetAckedAt(), 0)
+		}
+		store.memory.restoreAck(ack.GetPublicKey(), ack.GetDeviceId(), ack.GetId(), acked_at)
+	case record.GetState() != nil:
+		state := record.GetState()
+		devices := map[string]*Device{}
+		for _, device := range state.GetDevices() {
+			devices[device.GetDeviceId()] = &Device{last_seen: time.Unix(device.GetLastSeen(), 0)}
+		}
+		store.memory.restoreState(state.GetPublicKey(), state.GetNextId(), devices)
+	}
+}
+
+// Returns the next record in the log along with the number of bytes it took up.
+func readLogRecord(reader *bufio.Reader) (*PBLogRecord, int64, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, err
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(reader, data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("truncated log record... %w", err)
+	}
+	record := &PBLogRecord{}
+	err = proto.Unmarshal(data, record)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not deserialize log record... %w", err)
+	}
+	return record, int64(len(binary.AppendUvarint(nil, length))) + int64(length), nil
+}
+
+func writeLogRecord(writer io.Writer, record *PBLogRecord) error {
+	data, err := proto.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("could not serialize log record... %w", err)
+	}
+	prefix := binary.AppendUvarint(nil, uint64(len(data)))
+	_, err = writer.Write(append(prefix, data...))
+	return err
+}
+
+// The log is synced before returning, so a 200 from /publish
+// means the envelope is on disk. If the write fails, the log is cut
+// back to where it was, so a partial record can't take the records
+// after it down with it on replay.
+func (store *FileMailboxStore) Enqueue(pub_key string, payload []byte) (uint64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	stat, err := store.file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("could not read storage file... %w", err)
+	}
+	envelope, err := store.memory.enqueue(pub_key, payload)
+	if err != nil {
+		return 0, err
+	}
+	err = writeLogRecord(store.file, &PBLogRecord{
+		Record: &PBLogRecord_Enqueue{Enqueue: &PBLogEnqueue{
+			PublicKey: pub_key,
+			Id:        envelope.id,
+			Payload:   envelope.payload,
+			ExpiresAt: envelope.expires_at.Unix(),
+		}},
+	})
+	if err == nil {
+		err = store.file.Sync()
+	}
+	if err != nil {
+		os.Truncate(store.path, stat.Size())
+		store.memory.remove(pub_key, envelope.id)
+		return 0, fmt.Errorf("could not write envelope to storage... %w", err)
+	}
+	return envelope.id, nil
+}
+
+// A new device is written to the log, so that after a restart the
+// other devices' acks don't remove what it hasn't been sent yet.
+func (store *FileMailboxStore) Pending(pub_key string, device_id string, after_id uint64) ([]Envelope, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	pending, is_new, err := store.memory.pending(pub_key, device_id, after_id)
+	if err != nil || !is_new {
+		return pending, err
+	}
+	err = writeLogRecord(store.file, &PBLogRecord{
+		Record: &PBLogRecord_State{State: &PBLogState{
+			PublicKey: pub_key,
+			Devices:   []*PBLogDevice{{DeviceId: device_id, LastSeen: time.Now().Unix()}},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not write device to storage... %w", err)
+	}
+	return pending, nil
+}
+
+// Acks are not synced. Losing one in a crash only means the envelope
+// is delivered again, which at-least-once delivery allows.
+func (store *FileMailboxStore) Ack(pub_key string, device_id string, id uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.memory.Ack(pub_key, device_id, id)
+	return writeLogRecord(store.file, &PBLogRecord{
+		Record: &PBLogRecord_Ack{Ack: &PBLogAck{
+			PublicKey: pub_key,
+			Id:        id,
+			DeviceId:  device_id,
+			AckedAt:   time.Now().Unix(),
+		}},
+	})
+}
+
+// Writes the live state to a new log and swaps it in for the old one.
+// Expired and acked envelopes, and dropped mailboxes, are left behind.
+func (store *FileMailboxStore) Compact(in_use func(pub_key string) bool) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.memory.Compact(in_use)
+	tmp_path := store.path + ".compact"
+	tmp_file, err := os.OpenFile(tmp_path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("could not create compacted log... %w", err)
+	}
+	writer := bufio.NewWriter(tmp_file)
+	err = store.writeSnapshot(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp_file.Sync()
+	}
+	tmp_file.Close()
+	if err != nil {
+		os.Remove(tmp_path)
+		return fmt.Errorf("could not write compacted log... %w", err)
+	}
+	err = os.Rename(tmp_path, store.path)
+	if err != nil {
+		return fmt.Errorf("could not replace log with compacted log... %w", err)
+	}
+	store.file.Close()
+	store.file, err = os.OpenFile(store.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("could not reopen storage file... %w", err)
+	}
+	return nil
+}
+
+// Writes a state record and the waiting envelopes for every mailbox.
+func (store *FileMailboxStore) writeSnapshot(writer io.Writer) error {
+	store.memory.mutex.Lock()
+	defer store.memory.mutex.Unlock()
+	for pub_key, box := range store.memory.mailboxes {
+		state := &PBLogState{PublicKey: pub_key, NextId: box.next_id}
+		for device_id, device := range box.devices {
+			state.Devices = append(state.Devices, &PBLogDevice{DeviceId: device_id, LastSeen: device.last_seen.Unix()})
+		}
+		err := writeLogRecord(writer, &PBLogRecord{
+			Record: &PBLogRecord_State{State: state},
+		})
+		if err != nil {
+			return err
+		}
+		for _, envelope := range box.envelopes {
+			var acked_by []string
+			for device_id := range envelope.acked_by {
+				acked_by = append(acked_by, device_id)
+			}
+			err = writeLogRecord(writer, &PBLogRecord{
+				Record: &PBLogRecord_Enqueue{Enqueue: &PBLogEnqueue{
+					PublicKey: pub_key,
+					Id:        envelope.id,
+					Payload:   envelope.payload,
+					ExpiresAt: envelope.expires_at.Unix(),
+					AckedBy:   acked_by,
+				}},
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (store *FileMailboxStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.file.Close()
+}
+
+// Opens the storage described by the host config.
+// Mailboxes are kept in memory unless a storage_file is configured.
+func OpenMailboxStorage(config *HostConfig) (MailboxStorage, error) {
+	if config.StorageFile == "" {
+		memory := NewMemoryMailboxStore(config.MailboxTTL, config.MailboxQuota)
+		memory.setMaxMailboxes(config.MaxMailboxes)
+		return memory, nil
+	}
+	store, err := OpenFileMailboxStore(config.StorageFile, config.MailboxTTL, config.MailboxQuota)
+	if err != nil {
+		return nil, err
+	}
+	store.memory.setMaxMailboxes(config.MaxMailboxes)
+	return store, nil
+}
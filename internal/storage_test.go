@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Envelopes and acks written to the log should be there after reopening it.
+func TestFileMailboxStoreReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.log")
+	store, err := OpenFileMailboxStore(path, time.Hour, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := store.Enqueue("bob", []byte("one"))
+	second, _ := store.Enqueue("bob", []byte("two"))
+	store.Ack("bob", "", first)
+	store.Close()
+
+	store, err = OpenFileMailboxStore(path, time.Hour, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	pending, _ := store.Pending("bob", "", 0)
+	if len(pending) != 1 || string(pending[0].payload) != "two" {
+		t.Errorf("unexpected envelopes after replay: %v", pending)
+	}
+	third, _ := store.Enqueue("bob", []byte("three"))
+	if third <= second {
+		t.Errorf("envelope IDs should carry on after a restart, got %v", third)
+	}
+}
+
+// Compacting should shrink the log without losing live envelopes or counters.
+func TestFileMailboxStoreCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.log")
+	store, err := OpenFileMailboxStore(path, time.Hour, 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		id, _ := store.Enqueue("bob", make([]byte, 100))
+		store.Ack("bob", "", id)
+	}
+	keep, _ := store.Enqueue("bob", []byte("keep me"))
+	before, _ := os.Stat(path)
+	err = store.Compact(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Errorf("compaction did not shrink the log: %v >= %v", after.Size(), before.Size())
+	}
+	store.Close()
+
+	store, err = OpenFileMailboxStore(path, time.Hour, 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	pending, _ := store.Pending("bob", "", 0)
+	if len(pending) != 1 || pending[0].id != keep {
+		t.Errorf("unexpected envelopes after compaction: %v", pending)
+	}
+}
+
+// A record cut off half way through should be dropped, not fail the open.
+func TestFileMailboxStoreTruncatedLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.log")
+	store, _ := OpenFileMailboxStore(path, time.Hour, 1024)
+	store.Enqueue("bob", []byte("one"))
+	store.Enqueue("bob", []byte("two"))
+	store.Close()
+	info, _ := os.Stat(path)
+	os.Truncate(path, info.Size()-2)
+
+	store, err := OpenFileMailboxStore(path, time.Hour, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	pending, _ := store.Pending("bob", "", 0)
+	if len(pending) != 1 {
+		t.Errorf("expected only the intact envelope, got %v", pending)
+	}
+}
+
+// An envelope that couldn't be written is taken back, and the log
+// is left as it was.
+func TestFileMailboxStoreFailedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.log")
+	store, _ := OpenFileMailboxStore(path, time.Hour, 1024)
+	store.Enqueue("bob", []byte("one"))
+	before, _ := os.Stat(path)
+	writable := store.file
+	store.file, _ = os.Open(path)
+	if _, err := store.Enqueue("bob", []byte("two")); err == nil {
+		t.Fatal("expected the write to a read-only log to fail")
+	}
+	store.file.Close()
+	store.file = writable
+	if after, _ := os.Stat(path); after.Size() != before.Size() {
+		t.Errorf("the log changed size from %v to %v", before.Size(), after.Size())
+	}
+	if pending, _ := store.Pending("bob", "", 0); len(pending) != 1 {
+		t.Errorf("the failed envelope is still queued: %v", pending)
+	}
+	store.Enqueue("bob", []byte("three"))
+	store.Close()
+
+	store, _ = OpenFileMailboxStore(path, time.Hour, 1024)
+	defer store.Close()
+	pending, _ := store.Pending("bob", "", 0)
+	if len(pending) != 2 || string(pending[1].payload) != "three" {
+		t.Errorf("unexpected envelopes after replay: %v", pending)
+	}
+}
+
+// Devices, and what each has acked, survive a restart and compaction.
+func TestFileMailboxStoreDevices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.log")
+	store, _ := OpenFileMailboxStore(path, time.Hour, 1024)
+	store.Pending("bob", "laptop", 0)
+	store.Pending("bob", "phone", 0)
+	id, _ := store.Enqueue("bob", []byte("one"))
+	store.Ack("bob", "laptop", id)
+	store.Close()
+
+	for _, compact := range []bool{false, true} {
+		store, _ = OpenFileMailboxStore(path, time.Hour, 1024)
+		if compact {
+			store.Compact(nil)
+			store.Close()
+			store, _ = OpenFileMailboxStore(path, time.Hour, 1024)
+		}
+		if pending, _ := store.Pending("bob", "laptop", 0); len(pending) != 0 {
+			t.Errorf("the laptop's ack was lost: %v", pending)
+		}
+		if pending, _ := store.Pending("bob", "phone", 0); len(pending) != 1 {
+			t.Errorf("the phone's envelope was lost: %v", pending)
+		}
+		store.Close()
+	}
+}
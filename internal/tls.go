@@ -0,0 +1,139 @@
+/*
+TLS for the relay server and certificate pinning for clients.
+
+The server can be given a certificate and key, or asked to generate a
+self-signed pair the first time it runs. Clients trust a self-signed
+relay by pinning the SHA-256 fingerprint of its certificate in the
+group's config with tls_fingerprint. The server prints the fingerprint
+when it starts.
+*/
+
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const SELF_SIGNED_CERT_LIFETIME = time.Hour * 24 * 365 * 5
+
+// Writes a new self-signed certificate and private key to the given
+// paths, unless a certificate is already there.
+func EnsureSelfSignedCert(cert_file string, key_file string) error {
+	if _, err := os.Stat(cert_file); err == nil {
+		return nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("could not generate TLS key... %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("could not generate certificate serial number... %w", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "peppermint relay"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(SELF_SIGNED_CERT_LIFETIME),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	cert_der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("could not create certificate... %w", err)
+	}
+	key_der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("could not marshal TLS key... %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(cert_file), 0700)
+	if err != nil {
+		return fmt.Errorf("could not create certificate directory... %w", err)
+	}
+	err = os.WriteFile(key_file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key_der}), 0600)
+	if err != nil {
+		return fmt.Errorf("could not write TLS key... %w", err)
+	}
+	err = os.WriteFile(cert_file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert_der}), 0644)
+	if err != nil {
+		return fmt.Errorf("could not write certificate... %w", err)
+	}
+	return nil
+}
+
+// Returns the hex encoded SHA-256 hash of the DER encoded certificate,
+// which is what clients pin.
+func CertificateFingerprint(cert_der []byte) string {
+	digest := sha256.Sum256(cert_der)
+	return hex.EncodeToString(digest[:])
+}
+
+// Reads the first certificate in a PEM file and returns its fingerprint.
+func CertificateFileFingerprint(cert_file string) (string, error) {
+	cert_pem, err := os.ReadFile(cert_file)
+	if err != nil {
+		return "", fmt.Errorf("could not read certificate... %w", err)
+	}
+	block, _ := pem.Decode(cert_pem)
+	if block == nil {
+		return "", fmt.Errorf("no PEM data found in %v", cert_file)
+	}
+	return CertificateFingerprint(block.Bytes), nil
+}
+
+// Allows fingerprints to be written in upper case or with colons,
+// the way most tools print them.
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+}
+
+// Returns the client used for every request to the server.
+// With a fingerprint, the server's certificate must match it and the
+// usual chain verification is skipped, so a self-signed relay can be
+// trusted. Without one, the default client and CA roots are used.
+func NewHTTPClient(fingerprint string) *http.Client {
+	if fingerprint == "" {
+		return http.DefaultClient
+	}
+	pinned := normalizeFingerprint(fingerprint)
+	tls_config := &tls.Config{
+		// the pin replaces the chain check, see VerifyPeerCertificate
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(raw_certs [][]byte, _ [][]*x509.Certificate) error {
+			if len(raw_certs) == 0 {
+				return errors.New("server did not present a certificate")
+			}
+			presented := CertificateFingerprint(raw_certs[0])
+			if subtle.ConstantTimeCompare([]byte(presented), []byte(pinned)) != 1 {
+				return fmt.Errorf("server certificate fingerprint %v does not match tls_fingerprint", presented)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tls_config
+	return &http.Client{Transport: transport}
+}
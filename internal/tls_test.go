@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Starts a TLS test server using a freshly generated self-signed cert,
+// and returns it along with the cert's fingerprint.
+func newSelfSignedServer(t *testing.T) (*httptest.Server, string) {
+	dir := t.TempDir()
+	cert_file, key_file := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	err := EnsureSelfSignedCert(cert_file, key_file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.LoadX509KeyPair(cert_file, key_file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := CertificateFileFingerprint(cert_file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	return server, fingerprint
+}
+
+func TestPinnedFingerprint(t *testing.T) {
+	server, fingerprint := newSelfSignedServer(t)
+	defer server.Close()
+	// fingerprints are often written with colons and in upper case
+	var pretty []string
+	for i := 0; i < len(fingerprint); i += 2 {
+		pretty = append(pretty, strings.ToUpper(fingerprint[i:i+2]))
+	}
+	_, err := NewHTTPClient(strings.Join(pretty, ":")).Get(server.URL)
+	if err != nil {
+		t.Errorf("pinned client should trust the self-signed server: %v", err)
+	}
+}
+
+func TestWrongFingerprint(t *testing.T) {
+	server, _ := newSelfSignedServer(t)
+	defer server.Close()
+	_, err := NewHTTPClient(strings.Repeat("00", 32)).Get(server.URL)
+	if err == nil {
+		t.Error("client should refuse a certificate that doesn't match the pin")
+	}
+	_, err = NewHTTPClient("").Get(server.URL)
+	if err == nil {
+		t.Error("client without a pin should refuse a self-signed certificate")
+	}
+}
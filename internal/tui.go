@@ -0,0 +1,688 @@
+/*
+A full-screen terminal UI for reading and writing in one place.
+
+The screen has a sidebar listing the groups in the config, with how
+many unread messages each has, and the members of the group being
+shown, marked when the relay says they're online. Next to it is the
+message pane, which scrolls back through what has arrived, and below
+both is the line being written. The title bar shows the connection.
+
+It sits on the same Messanger and transports as 'peppermint chat':
+one reader session for every group on the relay, and a Messanger per
+group sharing it to publish. The reader hands messages to the TUI
+through SetDisplay instead of printing them, and anything else
+written to output shows up in the message pane as a notice.
+
+Keys:
+  - Enter sends the line, or runs /file <path> or /quit
+  - Tab or Ctrl-N goes to the next group, Shift-Tab or Ctrl-P back
+  - PgUp and PgDn scroll a page, Up and Down a line, End goes to the newest
+  - Ctrl-U clears the line, Ctrl-C or Ctrl-D quits
+*/
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	TUI_SIDEBAR_WIDTH = 24
+	// how many messages and notices each group keeps
+	TUI_HISTORY_LIMIT = 1000
+
+	tui_reverse = "\x1b[7m"
+	tui_bold    = "\x1b[1m"
+	tui_dim     = "\x1b[2m"
+	tui_reset   = "\x1b[0m"
+)
+
+// A key press. Characters are their rune, other keys are negative.
+type tuiKey rune
+
+const (
+	KEY_ENTER tuiKey = -(iota + 1)
+	KEY_BACKSPACE
+	KEY_NEXT_GROUP
+	KEY_PREVIOUS_GROUP
+	KEY_PAGE_UP
+	KEY_PAGE_DOWN
+	KEY_UP
+	KEY_DOWN
+	KEY_END
+	KEY_CLEAR
+	KEY_QUIT
+)
+
+// Escape sequences for the keys the TUI handles. Anything else
+// starting with an escape is skipped.
+var tui_escape_keys = []struct {
+	sequence string
+	key      tuiKey
+}{
+	{"\x1b[Z", KEY_PREVIOUS_GROUP},
+	{"\x1b[5~", KEY_PAGE_UP},
+	{"\x1b[6~", KEY_PAGE_DOWN},
+	{"\x1b[A", KEY_UP},
+	{"\x1b[B", KEY_DOWN},
+	{"\x1b[F", KEY_END},
+	{"\x1b[4~", KEY_END},
+	{"\x1bOF", KEY_END},
+}
+
+// Control characters for the keys the TUI handles.
+var tui_control_keys = map[byte]tuiKey{
+	'\r': KEY_ENTER,
+	'\n': KEY_ENTER,
+	127:  KEY_BACKSPACE,
+	8:    KEY_BACKSPACE,
+	'\t': KEY_NEXT_GROUP,
+	14:   KEY_NEXT_GROUP,
+	16:   KEY_PREVIOUS_GROUP,
+	21:   KEY_CLEAR,
+	3:    KEY_QUIT,
+	4:    KEY_QUIT,
+}
+
+// Turns what was read from the terminal into key presses.
+func parseKeys(data []byte) []tuiKey {
+	var keys []tuiKey
+	for len(data) > 0 {
+		if data[0] == 0x1b {
+			matched := false
+			for _, escape := range tui_escape_keys {
+				if bytes.HasPrefix(data, []byte(escape.sequence)) {
+					keys = append(keys, escape.key)
+					data = data[len(escape.sequence):]
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				data = skipEscape(data)
+			}
+			continue
+		}
+		if key, ok := tui_control_keys[data[0]]; ok {
+			keys = append(keys, key)
+			data = data[1:]
+			continue
+		}
+		if data[0] < ' ' {
+			data = data[1:]
+			continue
+		}
+		char, size := utf8.DecodeRune(data)
+		keys = append(keys, tuiKey(char))
+		data = data[size:]
+	}
+	return keys
+}
+
+// Drops an escape sequence the TUI doesn't know, up to its final byte.
+func skipEscape(data []byte) []byte {
+	if len(data) < 2 || data[1] != '[' {
+		return data[1:]
+	}
+	for i := 2; i < len(data); i++ {
+		if data[i] >= 0x40 && data[i] <= 0x7e {
+			return data[i+1:]
+		}
+	}
+	return nil
+}
+
+// Something shown in the message pane.
+type tuiEntry struct {
+	message DisplayedMessage
+	// set for lines written to output rather than messages
+	notice string
+}
+
+type tuiMember struct {
+	name string
+	key  string
+}
+
+type tuiGroup struct {
+	name      string
+	group_id  string
+	messanger *Messanger
+	members   []tuiMember
+	entries   []tuiEntry
+	unread    int
+}
+
+func newTUIGroup(name string, group_id string, messanger *Messanger) *tuiGroup {
+	group := &tuiGroup{name: name, group_id: group_id, messanger: messanger}
+	self_key := PublicKeyToString(messanger.identity.Public())
+	for _, friend := range messanger.recipients {
+		key := PublicKeyToString(friend.public_key)
+		if key != self_key {
+			group.members = append(group.members, tuiMember{name: friend.name, key: key})
+		}
+	}
+	return group
+}
+
+func (group *tuiGroup) add(entry tuiEntry) {
+	group.entries = append(group.entries, entry)
+	if len(group.entries) > TUI_HISTORY_LIMIT {
+		group.entries = group.entries[len(group.entries)-TUI_HISTORY_LIMIT:]
+	}
+}
+
+type TUI struct {
+	mutex   sync.Mutex
+	groups  []*tuiGroup
+	current int
+	input   []rune
+	// lines scrolled back from the newest
+	scroll     int
+	connection string
+	// each sent message's delivery summary, by message ID
+	delivery map[string]string
+	// nil until the relay says who is online
+	online map[string]bool
+	// text written to output since the last newline
+	partial string
+	// lines in the message pane at the last render, for paging
+	page int
+	// signalled whenever the screen needs drawing again
+	redraw chan struct{}
+	// messages are sent one at a time, in order, off the input loop
+	sends chan func()
+}
+
+func NewTUI(groups []*tuiGroup) *TUI {
+	return &TUI{
+		groups:     groups,
+		connection: "connecting…",
+		delivery:   map[string]string{},
+		page:       1,
+		redraw:     make(chan struct{}, 1),
+		sends:      make(chan func(), 16),
+	}
+}
+
+func (tui *TUI) requestRedraw() {
+	select {
+	case tui.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// The group a message belongs in, or the one being shown
+// for a group that isn't in the sidebar.
+func (tui *TUI) groupFor(group_id string) *tuiGroup {
+	for _, group := range tui.groups {
+		if group.group_id == group_id {
+			return group
+		}
+	}
+	return tui.groups[tui.current]
+}
+
+func (tui *TUI) ShowMessage(message DisplayedMessage) {
+	tui.mutex.Lock()
+	defer tui.mutex.Unlock()
+	group := tui.groupFor(message.group_id)
+	group.add(tuiEntry{message: message})
+	if group != tui.groups[tui.current] {
+		group.unread++
+	}
+	tui.requestRedraw()
+}
+
+func (tui *TUI) ShowConnectionStatus(status string) {
+	tui.mutex.Lock()
+	defer tui.mutex.Unlock()
+	tui.connection = status
+	tui.requestRedraw()
+}
+
+func (tui *TUI) ShowDeliveryStatus(message_id string, summary string) {
+	tui.mutex.Lock()
+	defer tui.mutex.Unlock()
+	tui.delivery[message_id] = summary
+	tui.requestRedraw()
+}
+
+// Shows whatever is written to output as notices in the group being
+// shown. A line being redrawn with carriage returns, like a progress
+// count, is shown as it goes.
+func (tui *TUI) Write(data []byte) (int, error) {
+	tui.mutex.Lock()
+	defer tui.mutex.Unlock()
+	lines := strings.Split(tui.partial+string(data), "\n")
+	for _, line := range lines[:len(lines)-1] {
+		line = afterCarriageReturn(line)
+		if strings.TrimSpace(line) != "" {
+			tui.groups[tui.current].add(tuiEntry{notice: line})
+		}
+	}
+	tui.partial = afterCarriageReturn(lines[len(lines)-1])
+	tui.requestRedraw()
+	return len(data), nil
+}
+
+func afterCarriageReturn(line string) string {
+	return line[strings.LastIndex(line, "\r")+1:]
+}
+
+// Applies a key press. Returns a send to make, if the key was Enter,
+// and whether to quit.
+func (tui *TUI) handleKey(key tuiKey) (func(), bool) {
+	tui.mutex.Lock()
+	defer tui.mutex.Unlock()
+	defer tui.requestRedraw()
+	switch key {
+	case KEY_ENTER:
+		line := strings.TrimSpace(string(tui.input))
+		tui.input = nil
+		tui.scroll = 0
+		return tui.submit(line)
+	case KEY_BACKSPACE:
+		if len(tui.input) > 0 {
+			tui.input = tui.input[:len(tui.input)-1]
+		}
+	case KEY_CLEAR:
+		tui.input = nil
+	case KEY_QUIT:
+		return nil, true
+	case KEY_NEXT_GROUP:
+		tui.showGroup((tui.current + 1) % len(tui.groups))
+	case KEY_PREVIOUS_GROUP:
+		tui.showGroup((tui.current + len(tui.groups) - 1) % len(tui.groups))
+	case KEY_PAGE_UP:
+		tui.scroll += tui.page
+	case KEY_PAGE_DOWN:
+		tui.scroll -= tui.page
+	case KEY_UP:
+		tui.scroll++
+	case KEY_DOWN:
+		tui.scroll--
+	case KEY_END:
+		tui.scroll = 0
+	default:
+		if key > 0 {
+			tui.input = append(tui.input, rune(key))
+		}
+	}
+	if tui.scroll < 0 {
+		tui.scroll = 0
+	}
+	return nil, false
+}
+
+func (tui *TUI) showGroup(index int) {
+	tui.current = index
+	tui.groups[index].unread = 0
+	tui.scroll = 0
+}
+
+// Works out what to do with a line that was entered.
+func (tui *TUI) submit(line string) (func(), bool) {
+	messanger := tui.groups[tui.current].messanger
+	switch {
+	case line == "":
+		return nil, false
+	case line == "/quit":
+		return nil, true
+	case strings.HasPrefix(line, "/file "):
+		path := strings.TrimSpace(strings.TrimPrefix(line, "/file "))
+		return func() {
+			if err := messanger.SendFile(path); err != nil {
+				fmt.Fprintln(output, "Could not send", path, "...", err)
+			}
+		}, false
+	}
+	return func() { messanger.Publish(line) }, false
+}
+
+// Everything on the screen at the given size, one string per row.
+// The cursor goes at the end of the last row.
+func (tui *TUI) render(width int, height int) []string {
+	if width < 8 {
+		width = 8
+	}
+	group := tui.groups[tui.current]
+	input := "> " + string(tui.input)
+	if overflow := utf8.RuneCountInString(input) - (width - 1); overflow > 0 {
+		input = string([]rune(input)[overflow:])
+	}
+	if height < 4 {
+		return []string{input}
+	}
+	rows := []string{tui_reverse + padRight(fitText(" peppermint - "+group.name+" - "+tui.connection, width), width) + tui_reset}
+
+	body_height := height - 3
+	sidebar_width := TUI_SIDEBAR_WIDTH
+	if width < sidebar_width*2 {
+		sidebar_width = 0
+	}
+	pane_width := width - sidebar_width
+	tui.page = body_height
+	pane := tui.paneLines(group, pane_width)
+	max_scroll := len(pane) - body_height
+	if max_scroll < 0 {
+		max_scroll = 0
+	}
+	if tui.scroll > max_scroll {
+		tui.scroll = max_scroll
+	}
+	end := len(pane) - tui.scroll
+	start := end - body_height
+	var shown []string
+	if start < 0 {
+		shown = make([]string, -start)
+		start = 0
+	}
+	shown = append(shown, pane[start:end]...)
+	sidebar := tui.sidebarLines(body_height)
+	for i := 0; i < body_height; i++ {
+		row := shown[i]
+		if sidebar_width > 0 {
+			row = padRight(fitText(sidebar[i], sidebar_width-1), sidebar_width-1) + "│" + row
+		}
+		rows = append(rows, row)
+	}
+
+	separator := strings.Repeat("─", width)
+	if tui.scroll > 0 {
+		separator = fitText(fmt.Sprintf("── %v more below, End to jump back ", tui.scroll)+separator, width)
+	}
+	return append(rows, tui_dim+separator+tui_reset, input)
+}
+
+// The groups with their unread counts, then the members of the group
+// being shown. Online members are marked with a filled circle once the
+// relay has said who is online.
+func (tui *TUI) sidebarLines(height int) []string {
+	lines := []string{" GROUPS"}
+	for i, group := range tui.groups {
+		line := "  " + group.name
+		if i == tui.current {
+			line = "> " + group.name
+		}
+		if group.unread > 0 {
+			line += fmt.Sprintf(" (%v)", group.unread)
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", " MEMBERS")
+	for _, member := range tui.groups[tui.current].members {
+		marker := " "
+		if tui.online != nil {
+			marker = "○"
+			if tui.online[member.key] {
+				marker = "●"
+			}
+		}
+		lines = append(lines, " "+marker+" "+member.name)
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines[:height]
+}
+
+// The group's messages and notices, wrapped to the pane.
+// Your own messages are right justified, as the reader prints them.
+func (tui *TUI) paneLines(group *tuiGroup, width int) []string {
+	var lines []string
+	for _, entry := range group.entries {
+		if entry.notice != "" {
+			for _, line := range wrapText(entry.notice, width) {
+				lines = append(lines, tui_dim+line+tui_reset)
+			}
+			continue
+		}
+		message := entry.message
+		label := ""
+		if message.group_id != group.group_id {
+			label = fmt.Sprintf("unknown group %q", message.group_id)
+		}
+		header := messageHeader(message.senderLabel(), message.timestamp, label)
+		if summary, ok := tui.delivery[message.message_id]; ok && message.from_self {
+			header += " - " + summary
+		}
+		header_lines := wrapText(header, width)
+		text_lines := wrapText(message.text, width)
+		if message.from_self {
+			for i, line := range header_lines {
+				header_lines[i] = padLeft(line, width)
+			}
+			for i, line := range text_lines {
+				text_lines[i] = padLeft(line, width)
+			}
+		}
+		for _, line := range header_lines {
+			lines = append(lines, tui_bold+line+tui_reset)
+		}
+		lines = append(lines, text_lines...)
+		lines = append(lines, "")
+	}
+	if tui.partial != "" {
+		lines = append(lines, tui_dim+fitText(tui.partial, width)+tui_reset)
+	}
+	return lines
+}
+
+// Wraps the text to the width, breaking between words where it can.
+func wrapText(text string, width int) []string {
+	if width < 1 {
+		width = 1
+	}
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := []rune{}
+		for _, word := range strings.Split(paragraph, " ") {
+			word_runes := []rune(word)
+			if len(line) > 0 && len(line)+1+len(word_runes) > width {
+				lines = append(lines, string(line))
+				line = []rune{}
+			}
+			if len(line) > 0 {
+				line = append(line, ' ')
+			}
+			line = append(line, word_runes...)
+			for len(line) > width {
+				lines = append(lines, string(line[:width]))
+				line = line[width:]
+			}
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// Cuts the text down to the width.
+func fitText(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return text
+}
+
+func padRight(text string, width int) string {
+	if padding := width - visibleLength(text); padding > 0 {
+		return text + strings.Repeat(" ", padding)
+	}
+	return text
+}
+
+func padLeft(text string, width int) string {
+	if padding := width - visibleLength(text); padding > 0 {
+		return strings.Repeat(" ", padding) + text
+	}
+	return text
+}
+
+// How many runes of the text are shown, leaving out styles.
+func visibleLength(text string) int {
+	for _, style := range []string{tui_reverse, tui_bold, tui_dim, tui_reset} {
+		text = strings.ReplaceAll(text, style, "")
+	}
+	return utf8.RuneCountInString(text)
+}
+
+// Draws the whole screen, leaving the cursor on the input line.
+func (tui *TUI) draw(out io.Writer, width int, height int) {
+	tui.mutex.Lock()
+	rows := tui.render(width, height)
+	tui.mutex.Unlock()
+	var screen strings.Builder
+	screen.WriteString("\x1b[?25l")
+	for i, row := range rows {
+		fmt.Fprintf(&screen, "\x1b[%d;1H%s\x1b[K", i+1, row)
+	}
+	fmt.Fprintf(&screen, "\x1b[%d;%dH\x1b[?25h", len(rows), visibleLength(rows[len(rows)-1])+1)
+	io.WriteString(out, screen.String())
+}
+
+// Reads key presses from the terminal until it closes.
+func readKeys(input io.Reader, keys chan<- []tuiKey) {
+	buffer := make([]byte, 256)
+	for {
+		read, err := input.Read(buffer)
+		if read > 0 {
+			keys <- parseKeys(buffer[:read])
+		}
+		if err != nil {
+			close(keys)
+			return
+		}
+	}
+}
+
+// Makes the sends entered, one at a time.
+func (tui *TUI) sendLoop() {
+	for send := range tui.sends {
+		send()
+	}
+}
+
+// Asks the relay who is online every PRESENCE_INTERVAL,
+// giving up if it can't say.
+func (tui *TUI) presenceLoop(transport PresenceTransport) {
+	for {
+		online, err := transport.Presence(tui.memberKeys())
+		if errors.Is(err, errPresenceNotSupported) {
+			return
+		}
+		if err == nil {
+			tui.mutex.Lock()
+			tui.online = online
+			tui.mutex.Unlock()
+			tui.requestRedraw()
+		}
+		time.Sleep(PRESENCE_INTERVAL)
+	}
+}
+
+// The keys of everyone in every group.
+func (tui *TUI) memberKeys() []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, group := range tui.groups {
+		for _, member := range group.members {
+			if !seen[member.key] {
+				seen[member.key] = true
+				keys = append(keys, member.key)
+			}
+		}
+	}
+	return keys
+}
+
+// Takes over the terminal until the user quits.
+func (tui *TUI) Run() error {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("could not take over the terminal... %w", err)
+	}
+	defer term.Restore(fd, state)
+	// use the alternate screen, so the shell comes back as it was
+	fmt.Fprint(os.Stdout, "\x1b[?1049h")
+	defer fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+	SetOutput(tui)
+	SetDisplay(tui)
+	defer SetOutput(os.Stdout)
+	defer SetDisplay(nil)
+
+	keys := make(chan []tuiKey)
+	go readKeys(os.Stdin, keys)
+	go tui.sendLoop()
+	resized := notifyResize()
+	for {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		tui.draw(os.Stdout, width, height)
+		select {
+		case pressed, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, key := range pressed {
+				send, quit := tui.handleKey(key)
+				if quit {
+					return nil
+				}
+				if send != nil {
+					tui.sends <- send
+				}
+			}
+		case <-tui.redraw:
+		case <-resized:
+		}
+	}
+}
+
+// Opens the TUI on the group in the config, with every other group
+// on the same relay in the sidebar. Groups on other relays are left
+// out, since the reader's session can't see their messages.
+func RunTUI(config *MessangerConfig, messanger *Messanger) {
+	groups := []*tuiGroup{newTUIGroup(config.Name, config.GroupID, messanger)}
+	others := append([]MessangerConfig{}, config.OtherGroups...)
+	sort.Slice(others, func(i, j int) bool { return others[i].Name < others[j].Name })
+	for _, other := range others {
+		if other.URL != config.URL {
+			continue
+		}
+		group_config := *config
+		group_config.Users = other.Users
+		group_config.Name = other.Name
+		group_config.GroupID = other.GroupID
+		group_config.OtherGroups = nil
+		group_messanger := ConfigureMessanger(&group_config)
+		group_messanger.transport = messanger.transport
+		group_messanger.tracker = messanger.tracker
+		groups = append(groups, newTUIGroup(other.Name, other.GroupID, group_messanger))
+	}
+	tui := NewTUI(groups)
+	go messanger.transport.Reader()
+	if presence, ok := messanger.transport.(PresenceTransport); ok {
+		go tui.presenceLoop(presence)
+	}
+	err := tui.Run()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package internal
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Signals whenever the terminal is resized.
+func notifyResize() <-chan os.Signal {
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	return resized
+}
@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Windows has no signal for a resize, so the size is checked
+// twice a second instead.
+func notifyResize() <-chan os.Signal {
+	resized := make(chan os.Signal, 1)
+	go func() {
+		last_width, last_height, _ := term.GetSize(int(os.Stdout.Fd()))
+		for range time.Tick(time.Millisecond * 500) {
+			width, height, _ := term.GetSize(int(os.Stdout.Fd()))
+			if width != last_width || height != last_height {
+				last_width, last_height = width, height
+				select {
+				case resized <- os.Interrupt:
+				default:
+				}
+			}
+		}
+	}()
+	return resized
+}
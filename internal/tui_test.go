@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("hé\x7f\r\x1b[5~\x1b[1;5C\x1b[Z\t\x03"))
+	expected := []tuiKey{'h', 'é', KEY_BACKSPACE, KEY_ENTER, KEY_PAGE_UP, KEY_PREVIOUS_GROUP, KEY_NEXT_GROUP, KEY_QUIT}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
+
+// Builds a TUI with two groups of one friend each.
+func newTestTUI() (*TUI, []*tuiGroup) {
+	self := NewKeyIdentity(GenerateRandomKey())
+	groups := []*tuiGroup{}
+	for _, name := range []string{"friends", "work"} {
+		messanger := &Messanger{identity: self, recipients: []FriendDetail{
+			{public_key: &GenerateRandomKey().PublicKey, name: name + "-buddy"},
+			{public_key: self.Public(), name: "Yourself"},
+		}}
+		groups = append(groups, newTUIGroup(name, name, messanger))
+	}
+	return NewTUI(groups), groups
+}
+
+func screenText(rows []string) string {
+	return strings.Join(rows, "\n")
+}
+
+func TestTUIGroups(t *testing.T) {
+	tui, groups := newTestTUI()
+	tui.ShowMessage(DisplayedMessage{message_id: "1", group_id: "friends", sender: "friends-buddy", text: "hello there"})
+	tui.ShowMessage(DisplayedMessage{message_id: "2", group_id: "work", sender: "work-buddy", text: "standup?"})
+	rows := tui.render(80, 12)
+	if len(rows) != 12 {
+		t.Fatalf("expected 12 rows, got %v", len(rows))
+	}
+	screen := screenText(rows)
+	if !strings.Contains(screen, "hello there") || strings.Contains(screen, "standup?") {
+		t.Error("expected only the current group's messages on screen")
+	}
+	if !strings.Contains(screen, "work (1)") {
+		t.Error("expected an unread count for the other group")
+	}
+	if len(groups[0].members) != 1 {
+		t.Error("you shouldn't be listed as a member")
+	}
+
+	tui.handleKey(KEY_NEXT_GROUP)
+	screen = screenText(tui.render(80, 12))
+	if !strings.Contains(screen, "standup?") || strings.Contains(screen, "work (1)") {
+		t.Error("switching groups should show its messages and clear its unread count")
+	}
+
+	tui.online = map[string]bool{groups[1].members[0].key: true}
+	if !strings.Contains(screenText(tui.render(80, 12)), "● work-buddy") {
+		t.Error("expected the online member to be marked")
+	}
+}
+
+func TestTUIInput(t *testing.T) {
+	tui, _ := newTestTUI()
+	for _, key := range parseKeys([]byte("hi")) {
+		tui.handleKey(key)
+	}
+	rows := tui.render(80, 12)
+	if rows[len(rows)-1] != "> hi" {
+		t.Errorf("unexpected input line %q", rows[len(rows)-1])
+	}
+	if send, quit := tui.handleKey(KEY_ENTER); send == nil || quit {
+		t.Error("expected a send for the line")
+	}
+	if len(tui.input) != 0 {
+		t.Error("the input should be cleared once sent")
+	}
+	for _, key := range parseKeys([]byte("/quit")) {
+		tui.handleKey(key)
+	}
+	if _, quit := tui.handleKey(KEY_ENTER); !quit {
+		t.Error("expected /quit to quit")
+	}
+
+	// output shows up as notices, with progress shown as it goes
+	tui.Write([]byte("\rUploading a.txt: 50%"))
+	if !strings.Contains(screenText(tui.render(80, 12)), "Uploading a.txt: 50%") {
+		t.Error("expected the progress to be shown")
+	}
+	tui.Write([]byte("\rUploading a.txt: 100%\n"))
+	if tui.partial != "" || tui.groups[0].entries[0].notice != "Uploading a.txt: 100%" {
+		t.Errorf("unexpected notices %v", tui.groups[0].entries)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	lines := wrapText("the quick brown fox\nabcdefghij", 9)
+	expected := []string{"the quick", "brown fox", "abcdefghi", "j"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("unexpected lines %q", lines)
+	}
+}
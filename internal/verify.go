@@ -0,0 +1,263 @@
+/*
+Checking that a friend's key really is theirs.
+
+Every key has a short fingerprint, the start of the SHA-256 hash of the
+key, which is easy to read out. Every pair of keys has a safety number,
+made the same way Signal makes them: each key is hashed many times into
+30 digits, and the two halves are put in order so both people see the
+same 60 digits. If the numbers match over a channel you trust (in
+person, or on a call), nobody swapped either key in the config.
+
+Keys that have been checked are kept in ~/.peppermint/verified, by their
+full fingerprint, so a verification lapses if the key ever changes.
+*/
+
+package internal
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// How many times each key is hashed for its half of a safety number.
+const SAFETY_NUMBER_ITERATIONS = 5200
+
+// Where verified keys are recorded, in ~/.peppermint.
+const VERIFIED_FILE = "verified"
+
+// The full SHA-256 fingerprint of a public key, hex encoded.
+func KeyFingerprint(pub_key crypto.PublicKey) string {
+	digest := sha256.Sum256(PublicKeyToBytes(pub_key))
+	return hex.EncodeToString(digest[:])
+}
+
+// The first 16 bytes of the fingerprint, in groups of four hex digits.
+func ShortFingerprint(pub_key crypto.PublicKey) string {
+	return groupText(KeyFingerprint(pub_key)[:32], 4)
+}
+
+// Hashes a key into the 30 digits that are its half of a safety number.
+func safetyNumberHalf(pub_key crypto.PublicKey) string {
+	key_bytes := PublicKeyToBytes(pub_key)
+	digest := append([]byte("peppermint-safety-number-v1"), key_bytes...)
+	for i := 0; i < SAFETY_NUMBER_ITERATIONS; i++ {
+		sum := sha512.Sum512(append(digest, key_bytes...))
+		digest = sum[:]
+	}
+	var digits strings.Builder
+	for chunk := 0; chunk < 6; chunk++ {
+		value := binary.BigEndian.Uint64(append([]byte{0, 0, 0}, digest[chunk*5:chunk*5+5]...))
+		fmt.Fprintf(&digits, "%05d", value%100000)
+	}
+	return digits.String()
+}
+
+// The 60 digit safety number for two keys. It is the same whichever
+// order the keys are given in.
+func SafetyNumber(key_a crypto.PublicKey, key_b crypto.PublicKey) string {
+	halves := []string{safetyNumberHalf(key_a), safetyNumberHalf(key_b)}
+	sort.Strings(halves)
+	return halves[0] + halves[1]
+}
+
+// Lays out a safety number as three lines of four groups of five digits.
+func FormatSafetyNumber(number string) string {
+	var lines []string
+	for start := 0; start < len(number); start += 20 {
+		end := start + 20
+		if end > len(number) {
+			end = len(number)
+		}
+		lines = append(lines, groupText(number[start:end], 5))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Splits the text into space separated groups of the given size.
+func groupText(text string, size int) string {
+	var groups []string
+	for start := 0; start < len(text); start += size {
+		end := start + size
+		if end > len(text) {
+			end = len(text)
+		}
+		groups = append(groups, text[start:end])
+	}
+	return strings.Join(groups, " ")
+}
+
+// Draws the text as a QR code with ANSI background colors, so it scans
+// the same on light and dark terminals.
+func PrintQRCode(writer io.Writer, text string) error {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return fmt.Errorf("could not make a QR code... %w", err)
+	}
+	const quiet_zone = 2
+	const black = "\033[40m  \033[0m"
+	const white = "\033[47m  \033[0m"
+	for y := -quiet_zone; y < code.Size+quiet_zone; y++ {
+		var line strings.Builder
+		for x := -quiet_zone; x < code.Size+quiet_zone; x++ {
+			if code.Black(x, y) {
+				line.WriteString(black)
+			} else {
+				line.WriteString(white)
+			}
+		}
+		fmt.Fprintln(writer, line.String())
+	}
+	return nil
+}
+
+// Fingerprints of the keys that have been verified, with the name
+// they were verified under.
+type VerifiedKeys map[string]string
+
+// The default place verified keys are kept.
+func DefaultVerifiedFile() string {
+	home, err := os.UserHomeDir()
+	CheckErrFatal(err)
+	return filepath.Join(home, ".peppermint", VERIFIED_FILE)
+}
+
+// Reads the verified keys file. A missing file means nobody is verified.
+// Each line is a fingerprint followed by the name it was verified under.
+func LoadVerifiedKeys(path string) (VerifiedKeys, error) {
+	verified := VerifiedKeys{}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return verified, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read verified keys... %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 2)
+		if fields[0] == "" || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		name := ""
+		if len(fields) == 2 {
+			name = fields[1]
+		}
+		verified[fields[0]] = name
+	}
+	return verified, scanner.Err()
+}
+
+func (verified VerifiedKeys) Has(pub_key crypto.PublicKey) bool {
+	_, ok := verified[KeyFingerprint(pub_key)]
+	return ok
+}
+
+func (verified VerifiedKeys) Mark(pub_key crypto.PublicKey, name string) {
+	verified[KeyFingerprint(pub_key)] = name
+}
+
+func (verified VerifiedKeys) Unmark(pub_key crypto.PublicKey) {
+	delete(verified, KeyFingerprint(pub_key))
+}
+
+// Writes the verified keys to a temporary file and moves it into place.
+func (verified VerifiedKeys) Save(path string) error {
+	fingerprints := make([]string, 0, len(verified))
+	for fingerprint := range verified {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+	var contents strings.Builder
+	for _, fingerprint := range fingerprints {
+		fmt.Fprintf(&contents, "%v %v\n", fingerprint, verified[fingerprint])
+	}
+	temp_path := path + ".tmp"
+	err := os.WriteFile(temp_path, []byte(contents.String()), 0600)
+	if err != nil {
+		return fmt.Errorf("could not write verified keys... %w", err)
+	}
+	return os.Rename(temp_path, path)
+}
+
+// Prints your own fingerprint, for 'peppermint fingerprint'.
+func ShowFingerprint(show_qr bool) {
+	ParseConfig()
+	identity, _ := parseIdentity()
+	fmt.Println("Your key fingerprint:")
+	fmt.Println(ShortFingerprint(identity.Public()))
+	if show_qr {
+		CheckErrFatal(PrintQRCode(os.Stdout, KeyFingerprint(identity.Public())))
+	}
+}
+
+// Shows the safety number between you and each user in the group, or
+// just the named one, for 'peppermint verify'. With a name and a
+// terminal, asks whether the numbers matched and records the answer.
+func VerifyContacts(group string, name string, show_qr bool) {
+	config := ParseConfigWithViper(group)
+	verified, err := LoadVerifiedKeys(config.VerifiedFile)
+	CheckErrFatal(err)
+	self_key := config.Identity.Public()
+	found := false
+	for _, user := range config.Users {
+		if name != "" && !strings.EqualFold(user.Name, name) {
+			continue
+		}
+		pub_key, err := ParsePublicKey([]byte(user.Key))
+		if err != nil {
+			fmt.Println("Could not parse the key for", user.Name, "...", err)
+			continue
+		}
+		found = true
+		status := "not verified"
+		if verified.Has(pub_key) {
+			status = "verified"
+		}
+		number := SafetyNumber(self_key, pub_key)
+		fmt.Printf("%v (%v)\nFingerprint: %v\nSafety number:\n%v\n\n", user.Name, status, ShortFingerprint(pub_key), FormatSafetyNumber(number))
+		if show_qr {
+			CheckErrFatal(PrintQRCode(os.Stdout, number))
+			fmt.Println()
+		}
+		if name != "" && HasTerminal() {
+			if askYesNo(fmt.Sprintf("Does %v see the same safety number? [y/N] ", user.Name)) {
+				verified.Mark(pub_key, user.Name)
+				fmt.Println("Marked", user.Name, "as verified")
+			} else if verified.Has(pub_key) {
+				verified.Unmark(pub_key)
+				fmt.Println("Marked", user.Name, "as not verified")
+			}
+			CheckErrFatal(verified.Save(config.VerifiedFile))
+		}
+	}
+	if !found && name != "" {
+		fmt.Printf("No user named %q in group %v\n", name, group)
+		os.Exit(1)
+	}
+	if !found {
+		fmt.Println("No users in group", group)
+		os.Exit(1)
+	}
+}
+
+// Asks a yes or no question on stdin. Anything but y or yes is a no.
+func askYesNo(question string) bool {
+	fmt.Print(question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
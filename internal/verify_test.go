@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafetyNumber(t *testing.T) {
+	alice := GenerateRandomKey()
+	bill, _ := GenerateKey(KEY_TYPE_ED25519)
+	number := SafetyNumber(&alice.PublicKey, bill.Public())
+	if len(number) != 60 || strings.Trim(number, "0123456789") != "" {
+		t.Fatalf("expected 60 digits, got %q", number)
+	}
+	if SafetyNumber(bill.Public(), &alice.PublicKey) != number {
+		t.Error("the safety number depends on the order of the keys")
+	}
+	carol := GenerateRandomKey()
+	if SafetyNumber(&alice.PublicKey, &carol.PublicKey) == number {
+		t.Error("different keys gave the same safety number")
+	}
+	if lines := strings.Split(FormatSafetyNumber(number), "\n"); len(lines) != 3 || len(lines[0]) != 23 {
+		t.Errorf("unexpected layout %q", lines)
+	}
+	var drawn bytes.Buffer
+	if err := PrintQRCode(&drawn, number); err != nil || drawn.Len() == 0 {
+		t.Errorf("could not draw the safety number as a QR code: %v", err)
+	}
+}
+
+func TestVerifiedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "verified")
+	verified, err := LoadVerifiedKeys(path)
+	if err != nil || len(verified) != 0 {
+		t.Fatalf("a missing file should mean nobody is verified: %v", err)
+	}
+	bill := GenerateRandomKey()
+	verified.Mark(&bill.PublicKey, "Bill")
+	if err := verified.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ := LoadVerifiedKeys(path)
+	if !loaded.Has(&bill.PublicKey) {
+		t.Error("Bill should be verified after reloading")
+	}
+	if loaded.Has(&GenerateRandomKey().PublicKey) {
+		t.Error("a new key for Bill should not be verified")
+	}
+	friends := parseFriendMap([]RecipientConfig{{Key: string(EncodePublicKey(bill)), Name: "Bill"}}, loaded)
+	friend := friends[PublicKeyToString(&bill.PublicKey)]
+	if friend.displayName() != "Bill (verified)" {
+		t.Errorf("unexpected name %q", friend.displayName())
+	}
+}
@@ -10,44 +10,89 @@ package internal
 
 import (
 	"context"
-	"crypto/rsa"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
+	"time"
 
+	"google.golang.org/protobuf/proto"
 	"nhooyr.io/websocket"
 )
 
 const (
-	HEADER_PUBLIC_KEY        = "PUBLIC_KEY"
-	HEADER_TARGET_PUBLIC_KEY = "TARGET_KEY"
-	HEADER_SIGNATURE_TOKEN   = "SIGNATURE_TOKEN"
-	HEADER_SIGNATURE_VALUE   = "SIGNATURE_VALUE"
+	HEADER_PUBLIC_KEY          = "PUBLIC_KEY"
+	HEADER_TARGET_PUBLIC_KEY   = "TARGET_KEY"
+	HEADER_SIGNATURE_TIMESTAMP = "SIGNATURE_TIMESTAMP"
+	HEADER_SIGNATURE_NONCE     = "SIGNATURE_NONCE"
+	HEADER_SIGNATURE_VALUE     = "SIGNATURE_VALUE"
+	// name of a PBSignatureAlgorithm, PKCS #1 v1.5 when missing
+	HEADER_SIGNATURE_ALGORITHM = "SIGNATURE_ALGORITHM"
+	// ID of the last envelope a reconnecting subscriber handled
+	HEADER_RESUME_AFTER = "RESUME_AFTER"
+	// which of the key's devices is subscribing, see mailbox.go
+	HEADER_DEVICE_ID     = "DEVICE_ID"
+	MAX_DEVICE_ID_LENGTH = 64
 )
 
+// Number of envelopes that can wait on a subscriber's channel
+// before the subscriber is considered too slow and is disconnected.
+const SUBSCRIBER_BUFFER = 64
+
+// subscribers maps a public key to every connection subscribed with
+// that key, keyed by connection ID. One person can be reading on
+// several devices at once.
 type ChatServer struct {
 	subscriber_mutex sync.Mutex
 	serve_mux        http.ServeMux
-	subscribers      map[string]Subscriber
+	subscribers      map[string]map[string]*Subscriber
+	mailboxes        MailboxStorage
+	replay_cache     *ReplayCache
+	// nil when the relay is open to everyone
+	access  *AccessList
+	prekeys *PrekeyDirectory
+	// nil when the relay doesn't store files
+	blobs *BlobStore
+	// see limits.go
+	max_envelope_size int64
+	max_batch_size    int64
 }
 
 type ChatClient struct {
 }
 
+// A single websocket connection subscribed to a public key.
+// Newly published envelopes are pushed onto envelopes.
 type Subscriber struct {
-	msgs chan []byte
+	connection_id string
+	envelopes     chan Envelope
+	// disconnects the subscriber, forcing it to reconnect and catch
+	// up from the mailbox.
+	cancel context.CancelFunc
 }
 
-func NewChatServer() *ChatServer {
+func NewChatServer(storage MailboxStorage, access *AccessList) *ChatServer {
 	cs := ChatServer{
-		subscribers: map[string]Subscriber{},
+		subscribers:  map[string]map[string]*Subscriber{},
+		mailboxes:    storage,
+		replay_cache: NewReplayCache(SIGNATURE_MAX_AGE),
+		access:       access,
+		prekeys:      NewPrekeyDirectory(),
+
+		max_envelope_size: DEFAULT_MAX_ENVELOPE_SIZE,
+		max_batch_size:    DEFAULT_MAX_BATCH_SIZE,
 	}
 	cs.serve_mux.HandleFunc("/subscribe", cs.authenticateRequest(cs.subscribeHandler))
 	cs.serve_mux.HandleFunc("/publish", cs.authenticateRequest(cs.publishHandler))
+	cs.serve_mux.HandleFunc("/publish/batch", cs.authenticateRequest(cs.publishBatchHandler))
+	cs.serve_mux.HandleFunc("/prekeys", cs.authenticateRequest(cs.prekeysHandler))
+	cs.serve_mux.HandleFunc("/blobs", cs.authenticateRequest(cs.createBlobHandler))
+	cs.serve_mux.HandleFunc("/blobs/", cs.authenticateRequest(cs.blobHandler))
+	cs.serve_mux.HandleFunc("/presence", cs.authenticateRequest(cs.presenceHandler))
 
 	return &cs
 }
@@ -55,16 +100,33 @@ func NewChatServer() *ChatServer {
 // subscribeHandler accepts the WebSocket connection and then subscribes
 // it to all future messages.
 func (cs *ChatServer) subscribeHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("got a request!")
 	pub_key := r.Header.Get(HEADER_PUBLIC_KEY)
+	// clients from before devices were tracked all share the empty ID
+	device_id := r.Header.Get(HEADER_DEVICE_ID)
+	if len(device_id) > MAX_DEVICE_ID_LENGTH {
+		http.Error(w, "device ID is too long", http.StatusBadRequest)
+		return
+	}
+	var resume_after uint64
+	if resume_header := r.Header.Get(HEADER_RESUME_AFTER); resume_header != "" {
+		var err error
+		resume_after, err = strconv.ParseUint(resume_header, 10, 64)
+		if err != nil {
+			http.Error(w, "could not parse resume ID from header", http.StatusBadRequest)
+			return
+		}
+	}
+	cs.advertiseLimits(w)
 	c, err := websocket.Accept(w, r, nil)
 	if err != nil {
 		fmt.Printf("could not accept websocket connection %v, %v", err, r.UserAgent())
 		return
 	}
 	defer c.Close(websocket.StatusInternalError, "")
+	// a publish frame carries a batch, so it gets the batch limit
+	c.SetReadLimit(cs.max_batch_size)
 
-	err = cs.subscribe(r.Context(), c, pub_key)
+	err = cs.subscribe(r.Context(), c, pub_key, device_id, resume_after)
 	// Cleanup
 	if errors.Is(err, context.Canceled) {
 		return
@@ -83,15 +145,25 @@ func (cs *ChatServer) subscribeHandler(w http.ResponseWriter, r *http.Request) {
 // The payload of the request will have the public key of the recip along
 // with the message itself.
 // The webserver doesn't look at the payload though, it looks for the recipient
-// in the request headers
+// in the request headers.
+// A 200 means the message was queued in the recipient's mailbox,
+// not that the recipient has read it.
 func (cs *ChatServer) publishHandler(w http.ResponseWriter, r *http.Request) {
+	pub_key := r.Header.Get(HEADER_TARGET_PUBLIC_KEY)
+	if !validTargetKey(pub_key) {
+		http.Error(w, "recipient is not a valid public key", http.StatusBadRequest)
+		return
+	}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, "failed to read request body", http.StatusInternalServerError)
 		return
 	}
 	defer r.Body.Close()
-	pub_key := r.Header.Get(HEADER_TARGET_PUBLIC_KEY)
+	if !cs.access.CanPublish(r.Header.Get(HEADER_PUBLIC_KEY), pub_key) {
+		http.Error(w, "recipient does not share a group with you", http.StatusForbidden)
+		return
+	}
 	message := body
 	err = cs.publish(pub_key, message)
 	if err != nil {
@@ -103,106 +175,359 @@ func (cs *ChatServer) publishHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// Publishes many envelopes with a single request.
+// The body is a PBBatchPublish with one entry per recipient, and the
+// response is a PBBatchResult saying which entries were queued.
+// A recipient that can't be published to doesn't fail the whole batch.
+func (cs *ChatServer) publishBatchHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+	batch := &PBBatchPublish{}
+	err = proto.Unmarshal(body, batch)
+	if err != nil {
+		http.Error(w, "could not deserialize batch", http.StatusBadRequest)
+		return
+	}
+	data, err := proto.Marshal(cs.publishBatch(r.Header.Get(HEADER_PUBLIC_KEY), batch))
+	if err != nil {
+		http.Error(w, "could not serialize batch result", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Publishes every entry in the batch and reports how each one went.
+// An entry with several target keys is fanned out to all of them,
+// with a status for each.
+func (cs *ChatServer) publishBatch(sender string, batch *PBBatchPublish) *PBBatchResult {
+	result := &PBBatchResult{RequestId: batch.GetRequestId()}
+	for _, entry := range batch.GetEntries() {
+		target_keys := entry.GetTargetKeys()
+		if len(target_keys) == 0 {
+			target_keys = []string{entry.GetTargetKey()}
+		}
+		for _, target_key := range target_keys {
+			result.Statuses = append(result.Statuses, cs.publishEntry(sender, target_key, entry.GetPayload()))
+		}
+	}
+	return result
+}
+
+// Publishes one payload from a batch to one recipient.
+func (cs *ChatServer) publishEntry(sender string, target_key string, payload []byte) *PBDeliveryStatus {
+	status := &PBDeliveryStatus{TargetKey: target_key, Queued: true}
+	if !validTargetKey(target_key) {
+		status.Queued = false
+		status.Error = "recipient is not a valid public key"
+		return status
+	}
+	if !cs.access.CanPublish(sender, target_key) {
+		status.Queued = false
+		status.Error = "recipient does not share a group with you"
+		return status
+	}
+	if int64(len(payload)) > cs.max_envelope_size {
+		status.Queued = false
+		status.Error = fmt.Sprintf("%v... the relay's limit is %v", errMessageTooLarge, formatSize(cs.max_envelope_size))
+		return status
+	}
+	err := cs.publish(target_key, payload)
+	if err != nil {
+		status.Queued = false
+		status.Error = fmt.Sprintf("unable to publish message... %v", err)
+	}
+	return status
+}
+
+// Mailboxes are only made for keys that parse, so senders can't fill
+// the relay with mailboxes for made-up recipients.
+func validTargetKey(target_key string) bool {
+	_, err := PublicKeyFromString(target_key)
+	return err == nil
+}
+
 // Creates a new subscriber object and adds it to the map.
-// Then listens for incoming messages to write to the websocket connection.
-func (cs *ChatServer) subscribe(ctx context.Context, conn *websocket.Conn, pub_key string) error {
-	sub := Subscriber{
-		msgs: make(chan []byte),
+// Then writes everything waiting in the subscriber's mailbox to the
+// websocket connection, and keeps doing so as new messages arrive.
+// Envelopes stay in the mailbox until the client acks them.
+// A reconnecting client passes the ID of the last envelope it handled.
+// Anything up to that ID is treated as acked by its device and is not
+// sent again.
+//
+// Every connection for a key is sent each new envelope. Acks only
+// count for the device they come from, so a device that is offline
+// is still sent everything the others have acked when it reconnects.
+func (cs *ChatServer) subscribe(ctx context.Context, conn *websocket.Conn, pub_key string, device_id string, resume_after uint64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sub := &Subscriber{
+		connection_id: RandomID(),
+		envelopes:     make(chan Envelope, SUBSCRIBER_BUFFER),
+		cancel:        cancel,
 	}
+	// register before reading the mailbox so nothing published
+	// in between is missed
 	cs.addSubscriber(pub_key, sub)
-	defer cs.deleteSubscriber(pub_key)
+	defer cs.deleteSubscriber(pub_key, sub.connection_id)
+	slog.Debug("Subscriber connected", "connection_id", sub.connection_id)
 
-	// the listen loop
-	// TODO: handle timeout and cancel
+	read_errors := make(chan error, 1)
+	go func() {
+		read_errors <- cs.readClientFrames(ctx, conn, pub_key, device_id)
+		cancel()
+	}()
+
+	// IDs increase within a mailbox, so we only need to remember
+	// the last one written to this connection.
+	last_sent := resume_after
+	if resume_after > 0 {
+		err := cs.ackThrough(pub_key, device_id, resume_after)
+		if err != nil {
+			return err
+		}
+	}
+	pending, err := cs.mailboxes.Pending(pub_key, device_id, last_sent)
+	if err != nil {
+		return fmt.Errorf("could not read mailbox... %w", err)
+	}
+	for _, envelope := range pending {
+		err = writeEnvelope(ctx, conn, envelope)
+		if err != nil {
+			return err
+		}
+		last_sent = envelope.id
+	}
 	for {
 		select {
-		case msg := <-sub.msgs:
-			err := conn.Write(ctx, websocket.MessageBinary, msg)
+		case envelope := <-sub.envelopes:
+			// already sent while flushing the mailbox
+			if envelope.id <= last_sent {
+				continue
+			}
+			err = writeEnvelope(ctx, conn, envelope)
 			if err != nil {
 				return err
 			}
+			last_sent = envelope.id
+		case <-ctx.Done():
+			select {
+			case err := <-read_errors:
+				return err
+			default:
+				return ctx.Err()
+			}
 		}
 	}
+}
 
+func writeEnvelope(ctx context.Context, conn *websocket.Conn, envelope Envelope) error {
+	frame := &PBServerFrame{
+		Frame: &PBServerFrame_Envelope{
+			Envelope: &PBEnvelope{Id: envelope.id, Payload: envelope.payload},
+		},
+	}
+	data, err := proto.Marshal(frame)
+	if err != nil {
+		return fmt.Errorf("could not serialize envelope... %w", err)
+	}
+	return conn.Write(ctx, websocket.MessageBinary, data)
 }
 
+// Acks, for the device, every envelope in the mailbox with an ID up
+// to and including id.
+func (cs *ChatServer) ackThrough(pub_key string, device_id string, id uint64) error {
+	pending, err := cs.mailboxes.Pending(pub_key, device_id, 0)
+	if err != nil {
+		return fmt.Errorf("could not read mailbox... %w", err)
+	}
+	for _, envelope := range pending {
+		if envelope.id > id {
+			break
+		}
+		err = cs.mailboxes.Ack(pub_key, device_id, envelope.id)
+		if err != nil {
+			return fmt.Errorf("could not record ack... %w", err)
+		}
+	}
+	return nil
+}
+
+// Reads client frames off the websocket.
+// Acks are recorded for the subscriber's device, and
+// publishes are handled just like a /publish/batch request, with the
+// result written back over the same connection.
+func (cs *ChatServer) readClientFrames(ctx context.Context, conn *websocket.Conn, pub_key string, device_id string) error {
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return err
+		}
+		frame := &PBClientFrame{}
+		err = proto.Unmarshal(data, frame)
+		if err != nil {
+			fmt.Println("could not deserialize client frame: ", err)
+			continue
+		}
+		if ack := frame.GetAck(); ack != nil {
+			err = cs.mailboxes.Ack(pub_key, device_id, ack.GetId())
+			if err != nil {
+				fmt.Println("could not record ack: ", err)
+			}
+		}
+		if batch := frame.GetPublish(); batch != nil {
+			result := &PBServerFrame{
+				Frame: &PBServerFrame_PublishResult{PublishResult: cs.publishBatch(pub_key, batch)},
+			}
+			data, err := proto.Marshal(result)
+			if err != nil {
+				return fmt.Errorf("could not serialize publish result... %w", err)
+			}
+			err = conn.Write(ctx, websocket.MessageBinary, data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Queues the message in the recipient's mailbox and hands it to
+// each of the recipient's connected subscribers.
 func (cs *ChatServer) publish(pub_key string, message []byte) error {
+	id, err := cs.mailboxes.Enqueue(pub_key, message)
+	if err != nil {
+		return err
+	}
+	envelope := Envelope{id: id, payload: message}
 	cs.subscriber_mutex.Lock()
 	defer cs.subscriber_mutex.Unlock()
-	sub, ok := cs.subscribers[pub_key]
-	if !ok {
-		return fmt.Errorf("given public key is not in subscriber map")
+	for _, sub := range cs.subscribers[pub_key] {
+		select {
+		case sub.envelopes <- envelope:
+		default:
+			// the subscriber isn't keeping up. It will get the
+			// envelope from the mailbox when it reconnects.
+			sub.cancel()
+		}
 	}
-	sub.msgs <- message
 	return nil
 }
 
 // Adds the given subscriber to the server's map of subscribers.
-func (cs *ChatServer) addSubscriber(pub_key string, sub Subscriber) {
+func (cs *ChatServer) addSubscriber(pub_key string, sub *Subscriber) {
+	cs.subscriber_mutex.Lock()
+	defer cs.subscriber_mutex.Unlock()
+	connections, ok := cs.subscribers[pub_key]
+	if !ok {
+		connections = map[string]*Subscriber{}
+		cs.subscribers[pub_key] = connections
+	}
+	connections[sub.connection_id] = sub
+}
+
+// Removes a single connection, leaving any other connections
+// for the same key alone.
+func (cs *ChatServer) deleteSubscriber(pub_key string, connection_id string) {
 	cs.subscriber_mutex.Lock()
-	cs.subscribers[pub_key] = sub
-	cs.subscriber_mutex.Unlock()
+	defer cs.subscriber_mutex.Unlock()
+	connections := cs.subscribers[pub_key]
+	delete(connections, connection_id)
+	if len(connections) == 0 {
+		delete(cs.subscribers, pub_key)
+	}
 }
 
-func (cs *ChatServer) deleteSubscriber(pub_key string) {
+// Whether any connection is subscribed with the key.
+func (cs *ChatServer) isSubscribed(pub_key string) bool {
 	cs.subscriber_mutex.Lock()
-	delete(cs.subscribers, pub_key)
-	cs.subscriber_mutex.Unlock()
+	defer cs.subscriber_mutex.Unlock()
+	return len(cs.subscribers[pub_key]) > 0
+}
+
+// Compacts the mailbox storage every interval, dropping expired
+// envelopes and empty mailboxes nobody is subscribed to, and deletes
+// expired blobs.
+func (cs *ChatServer) compactLoop(interval time.Duration) {
+	if interval <= 0 {
+		interval = DEFAULT_COMPACTION_INTERVAL
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := cs.mailboxes.Compact(cs.isSubscribed)
+		if err != nil {
+			fmt.Println("could not compact mailbox storage: ", err)
+		}
+		if cs.blobs != nil {
+			err = cs.blobs.Compact()
+			if err != nil {
+				fmt.Println("could not delete expired blobs: ", err)
+			}
+		}
+	}
 }
 
-func (cs *ChatServer) Run(port string) {
+// Serves over TLS when given a certificate and key, otherwise in the clear.
+// Only returns when the server stops, with the reason why.
+func (cs *ChatServer) Run(port string, cert_file string, key_file string) error {
 	fmt.Println("Listening on port: ", port)
-	err := http.ListenAndServe(fmt.Sprintf(":%s", port), &cs.serve_mux)
-	if err != nil {
-		fmt.Printf("Error serving app: %v\n", err)
+	if cert_file != "" {
+		return http.ListenAndServeTLS(fmt.Sprintf(":%s", port), cert_file, key_file, &cs.serve_mux)
 	}
-	os.Exit(1)
+	return http.ListenAndServe(fmt.Sprintf(":%s", port), &cs.serve_mux)
 }
 
 // run the webserver to accept websocket connections
-func HostWeb(port string) {
+func HostWeb(config *HostConfig) {
+	port := config.Port
 	if port == "" {
 		port = "80"
 	}
-	server := NewChatServer()
-	server.Run(port)
-}
-
-func GenerateRequestAuthHeaders(key *rsa.PrivateKey) *http.Header {
-	signature, token := CreateSignature(key)
-	headers := http.Header{}
-	headers.Add(HEADER_SIGNATURE_VALUE, signature)
-	headers.Add(HEADER_SIGNATURE_TOKEN, token)
-	headers.Add(HEADER_PUBLIC_KEY, PublicKeyToString(&key.PublicKey))
-	return &headers
-}
-
-func (cs *ChatServer) authenticateRequest(endpoint func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		signed_text, err := hex.DecodeString(r.Header.Get(HEADER_SIGNATURE_TOKEN))
-		if err != nil {
-			fmt.Println(err)
-			err_string := fmt.Sprintf("could not decode signed text from header: %v", err)
-			http.Error(w, err_string, http.StatusBadRequest)
-		}
-		signature, err := hex.DecodeString(r.Header.Get(HEADER_SIGNATURE_VALUE))
+	storage, err := OpenMailboxStorage(config)
+	if err != nil {
+		fmt.Println("Could not open mailbox storage: ", err)
+		os.Exit(1)
+	}
+	// os.Exit skips deferred calls, so the storage is closed by hand
+	exit := func(message string, err error) {
+		fmt.Println(message, err)
+		storage.Close()
+		os.Exit(1)
+	}
+	access, err := NewAccessList(config.AccessGroups)
+	if err != nil {
+		exit("Could not load access groups: ", err)
+	}
+	if config.TLSSelfSigned {
+		err = EnsureSelfSignedCert(config.TLSCertFile, config.TLSKeyFile)
 		if err != nil {
-			fmt.Println(err)
-			err_string := fmt.Sprintf("could not decode signature from header: %v", err)
-			http.Error(w, err_string, http.StatusBadGateway)
+			exit("Could not create a self-signed certificate: ", err)
 		}
-		pub_key_str := r.Header.Get(HEADER_PUBLIC_KEY)
-		pub_key, err := PublicKeyFromString(pub_key_str)
+	}
+	if config.TLSCertFile != "" {
+		fingerprint, err := CertificateFileFingerprint(config.TLSCertFile)
 		if err != nil {
-			http.Error(w, "Could not parse public key from header...", http.StatusInternalServerError)
-			return
-		}
-		verified := RSAVerify(pub_key, []byte(signed_text), []byte(signature))
-		if !verified {
-			fmt.Printf("Unable to verify request from IP: %v\n", r.RemoteAddr)
-			http.Error(w, "signature mismatch", http.StatusFailedDependency)
-			return
+			exit("Could not read TLS certificate: ", err)
 		}
-		endpoint(w, r)
+		fmt.Println("TLS certificate fingerprint (tls_fingerprint for clients): ", fingerprint)
+	}
+	server := NewChatServer(storage, access)
+	if config.MaxEnvelopeSize > 0 {
+		server.max_envelope_size = config.MaxEnvelopeSize
+	}
+	if config.MaxBatchSize > 0 {
+		server.max_batch_size = config.MaxBatchSize
+	}
+	server.blobs, err = OpenBlobStore(config.BlobDir, config.MaxBlobSize, config.MailboxTTL)
+	if err != nil {
+		exit("Could not open blob storage: ", err)
 	}
+	go server.compactLoop(config.CompactionInterval)
+	err = server.Run(port, config.TLSCertFile, config.TLSKeyFile)
+	exit("Error serving app: ", err)
 }
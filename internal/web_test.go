@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"context"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"nhooyr.io/websocket"
+)
+
+// Dials /subscribe on the test server as the owner of key.
+func dialSubscriber(t *testing.T, url string, key *rsa.PrivateKey) *websocket.Conn {
+	return dialDevice(t, url, key, "")
+}
+
+// Dials /subscribe as one of the key owner's devices.
+func dialDevice(t *testing.T, url string, key *rsa.PrivateKey, device_id string) *websocket.Conn {
+	headers := GenerateRequestAuthHeaders(NewKeyIdentity(key), http.MethodGet, "/subscribe", "", nil)
+	if device_id != "" {
+		headers.Set(HEADER_DEVICE_ID, device_id)
+	}
+	conn, _, err := websocket.Dial(context.Background(), url+"/subscribe", &websocket.DialOptions{HTTPHeader: *headers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// Reads the next envelope off the connection.
+func readEnvelope(t *testing.T, conn *websocket.Conn) *PBEnvelope {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	_, data, err := conn.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := &PBServerFrame{}
+	err = proto.Unmarshal(data, frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame.GetEnvelope()
+}
+
+// Waits for the server to register the expected number of connections for a key.
+func waitForSubscribers(t *testing.T, cs *ChatServer, pub_key string, count int) {
+	for i := 0; i < 100; i++ {
+		cs.subscriber_mutex.Lock()
+		connected := len(cs.subscribers[pub_key])
+		cs.subscriber_mutex.Unlock()
+		if connected == count {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("expected %v subscribers for key", count)
+}
+
+func TestPublishToOfflineRecipient(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	bob := GenerateRandomKey()
+	err := cs.publish(PublicKeyToString(&bob.PublicKey), []byte("while you were out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := dialSubscriber(t, server.URL, bob)
+	defer conn.Close(websocket.StatusNormalClosure, "")
+	if envelope := readEnvelope(t, conn); string(envelope.GetPayload()) != "while you were out" {
+		t.Errorf("unexpected payload: %v", envelope)
+	}
+}
+
+func TestPublishToEveryDevice(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	bob := GenerateRandomKey()
+	pub_key := PublicKeyToString(&bob.PublicKey)
+	laptop := dialSubscriber(t, server.URL, bob)
+	defer laptop.Close(websocket.StatusNormalClosure, "")
+	desktop := dialSubscriber(t, server.URL, bob)
+	waitForSubscribers(t, cs, pub_key, 2)
+
+	cs.publish(pub_key, []byte("hello"))
+	for _, conn := range []*websocket.Conn{laptop, desktop} {
+		if envelope := readEnvelope(t, conn); string(envelope.GetPayload()) != "hello" {
+			t.Errorf("unexpected payload: %v", envelope)
+		}
+	}
+
+	// closing one device leaves the other subscribed
+	desktop.Close(websocket.StatusNormalClosure, "")
+	waitForSubscribers(t, cs, pub_key, 1)
+	cs.publish(pub_key, []byte("still here?"))
+	if envelope := readEnvelope(t, laptop); string(envelope.GetPayload()) != "still here?" {
+		t.Errorf("unexpected payload: %v", envelope)
+	}
+}
+
+// An ack from one device doesn't take the envelope away from another
+// device that is offline.
+func TestAckPerDevice(t *testing.T) {
+	store := NewMemoryMailboxStore(0, 0)
+	cs := NewChatServer(store, nil)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	bob := GenerateRandomKey()
+	pub_key := PublicKeyToString(&bob.PublicKey)
+	queued := func() int {
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+		return len(store.mailboxes[pub_key].envelopes)
+	}
+	waitForQueued := func(count int) {
+		for i := 0; i < 100 && queued() != count; i++ {
+			time.Sleep(time.Millisecond * 10)
+		}
+		if queued() != count {
+			t.Fatalf("expected %v envelopes in the mailbox, got %v", count, queued())
+		}
+	}
+	// the phone has subscribed before, and is now offline
+	phone := dialDevice(t, server.URL, bob, "phone")
+	waitForSubscribers(t, cs, pub_key, 1)
+	phone.Close(websocket.StatusNormalClosure, "")
+	waitForSubscribers(t, cs, pub_key, 0)
+	laptop := dialDevice(t, server.URL, bob, "laptop")
+	defer laptop.Close(websocket.StatusNormalClosure, "")
+	waitForSubscribers(t, cs, pub_key, 1)
+
+	cs.publish(pub_key, []byte("hello"))
+	envelope := readEnvelope(t, laptop)
+	sendAck(context.Background(), laptop, envelope.GetId())
+	// resuming doesn't count for the other device either
+	cs.publish(pub_key, []byte("again"))
+	readEnvelope(t, laptop)
+	laptop.Close(websocket.StatusNormalClosure, "")
+	waitForSubscribers(t, cs, pub_key, 0)
+	headers := GenerateRequestAuthHeaders(NewKeyIdentity(bob), http.MethodGet, "/subscribe", "", nil)
+	headers.Set(HEADER_DEVICE_ID, "laptop")
+	headers.Set(HEADER_RESUME_AFTER, strconv.FormatUint(envelope.GetId()+1, 10))
+	laptop, _, err := websocket.Dial(context.Background(), server.URL+"/subscribe", &websocket.DialOptions{HTTPHeader: *headers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer laptop.Close(websocket.StatusNormalClosure, "")
+	waitForSubscribers(t, cs, pub_key, 1)
+	waitForQueued(2)
+
+	phone = dialDevice(t, server.URL, bob, "phone")
+	defer phone.Close(websocket.StatusNormalClosure, "")
+	for _, payload := range []string{"hello", "again"} {
+		envelope := readEnvelope(t, phone)
+		if string(envelope.GetPayload()) != payload {
+			t.Fatalf("expected the phone to get %q, got %q", payload, envelope.GetPayload())
+		}
+		sendAck(context.Background(), phone, envelope.GetId())
+	}
+	// every device has both now
+	waitForQueued(0)
+}
+
+func TestBatchPublishReportsEachRecipient(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 100), nil)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	alice := GenerateRandomKey()
+	bob := GenerateRandomKey()
+	carol := GenerateRandomKey()
+	webt := &WEBTransport{host_url: server.URL, identity: NewKeyIdentity(alice)}
+	errs := webt.BatchWriter([]OutboundMessage{
+		{friend: &FriendDetail{public_key: &bob.PublicKey, name: "bob"}, content: []byte("hi bob")},
+		{friend: &FriendDetail{public_key: &carol.PublicKey, name: "carol"}, content: make([]byte, 200)},
+	})
+	if errs[0] != nil {
+		t.Errorf("bob's envelope should have been queued: %v", errs[0])
+	}
+	if errs[1] == nil {
+		t.Error("carol's envelope is over the quota and should have failed")
+	}
+	pending, _ := cs.mailboxes.Pending(PublicKeyToString(&bob.PublicKey), "", 0)
+	if len(pending) != 1 || string(pending[0].payload) != "hi bob" {
+		t.Errorf("unexpected mailbox for bob: %v", pending)
+	}
+}
+
+// A batch entry for something that isn't a public key is refused
+// without making a mailbox for it.
+func TestPublishToInvalidKey(t *testing.T) {
+	store := NewMemoryMailboxStore(0, 0)
+	cs := NewChatServer(store, nil)
+	status := cs.publishEntry("", "not a key", []byte("hello"))
+	if status.GetQueued() {
+		t.Error("expected the envelope to be refused")
+	}
+	if len(store.mailboxes) != 0 {
+		t.Errorf("a mailbox was made for an invalid key: %v", store.mailboxes)
+	}
+}
+
+// One payload is copied into every recipient's mailbox.
+func TestFanoutPublish(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	alice := GenerateRandomKey()
+	friends := []*FriendDetail{
+		{public_key: &GenerateRandomKey().PublicKey, name: "bob"},
+		{public_key: &GenerateRandomKey().PublicKey, name: "carol"},
+	}
+	webt := &WEBTransport{host_url: server.URL, identity: NewKeyIdentity(alice)}
+	for _, err := range webt.FanoutWriter(friends, []byte("hi all")) {
+		if err != nil {
+			t.Errorf("the message should have been queued: %v", err)
+		}
+	}
+	for _, friend := range friends {
+		pending, _ := cs.mailboxes.Pending(PublicKeyToString(friend.public_key), "", 0)
+		if len(pending) != 1 || string(pending[0].payload) != "hi all" {
+			t.Errorf("unexpected mailbox for %v: %v", friend.name, pending)
+		}
+	}
+
+	// a relay that predates fan-out only reports on the empty key
+	old_relay := func(*PBBatchPublish) (*PBBatchResult, error) {
+		return &PBBatchResult{Statuses: []*PBDeliveryStatus{{Queued: true}}}, nil
+	}
+	var resent []OutboundMessage
+	errs := fanout(old_relay, func(batch []OutboundMessage) []error {
+		resent = batch
+		return make([]error, len(batch))
+	}, friends, []byte("hi all"))
+	if len(resent) != 2 || errs[0] != nil || errs[1] != nil {
+		t.Errorf("expected the message to be sent to each friend instead, got %v", errs)
+	}
+}
+
+func TestPublishOverSession(t *testing.T) {
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), nil)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	alice := GenerateRandomKey()
+	bob := GenerateRandomKey()
+	wst := NewWSTransport(&WEBTransport{host_url: server.URL, identity: NewKeyIdentity(alice)})
+	go wst.Reader()
+	err := wst.Writer(&FriendDetail{public_key: &bob.PublicKey, name: "bob"}, []byte("hi bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, _ := cs.mailboxes.Pending(PublicKeyToString(&bob.PublicKey), "", 0)
+	if len(pending) != 1 || string(pending[0].payload) != "hi bob" {
+		t.Errorf("unexpected mailbox for bob: %v", pending)
+	}
+}
+
+// Only connected keys are reported, and only to someone who shares a group.
+func TestPresence(t *testing.T) {
+	alice := GenerateRandomKey()
+	bob := GenerateRandomKey()
+	carol := GenerateRandomKey()
+	dave := GenerateRandomKey()
+	pem := func(key *rsa.PrivateKey) string { return string(EncodePublicKey(key)) }
+	access, _ := NewAccessList([]AccessGroupConfig{
+		{Name: "friends", Members: []string{pem(alice), pem(bob), pem(carol)}},
+		{Name: "work", Members: []string{pem(dave)}},
+	})
+	cs := NewChatServer(NewMemoryMailboxStore(0, 0), access)
+	server := httptest.NewServer(&cs.serve_mux)
+	defer server.Close()
+	for _, key := range []*rsa.PrivateKey{bob, dave} {
+		conn := dialSubscriber(t, server.URL, key)
+		defer conn.Close(websocket.StatusNormalClosure, "")
+		waitForSubscribers(t, cs, PublicKeyToString(&key.PublicKey), 1)
+	}
+	webt := &WEBTransport{host_url: server.URL, identity: NewKeyIdentity(alice)}
+	keys := []string{PublicKeyToString(&bob.PublicKey), PublicKeyToString(&carol.PublicKey), PublicKeyToString(&dave.PublicKey)}
+	online, err := webt.Presence(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !online[keys[0]] || online[keys[1]] || online[keys[2]] {
+		t.Errorf("expected only bob to be online, got %v", online)
+	}
+}
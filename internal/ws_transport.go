@@ -0,0 +1,157 @@
+/*
+A transport that reads and publishes over a single websocket session.
+
+WSTransport wraps a WEBTransport. The WEBTransport Reader keeps the
+authenticated /subscribe connection open (reconnecting as needed),
+and WSTransport writes publish frames to that same connection instead
+of making HTTP requests. The server answers each publish with a
+PBBatchResult carrying the request ID it was sent with.
+*/
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"nhooyr.io/websocket"
+)
+
+// How long a publish waits for a connection and then for its result.
+const SESSION_PUBLISH_TIMEOUT = time.Second * 5
+
+type WSTransport struct {
+	*WEBTransport
+	mutex      sync.Mutex
+	connection *websocket.Conn
+	// closed whenever a connection comes up, then replaced
+	connection_ready chan struct{}
+	next_request_id  uint64
+	pending_results  map[uint64]chan *PBBatchResult
+}
+
+func NewWSTransport(webt *WEBTransport) *WSTransport {
+	wst := &WSTransport{
+		WEBTransport:     webt,
+		connection_ready: make(chan struct{}),
+		pending_results:  map[uint64]chan *PBBatchResult{},
+	}
+	webt.session = wst
+	return wst
+}
+
+func (wst *WSTransport) connected(connection *websocket.Conn) {
+	wst.mutex.Lock()
+	defer wst.mutex.Unlock()
+	wst.connection = connection
+	close(wst.connection_ready)
+}
+
+// Fails every publish still waiting on a result from the old connection.
+func (wst *WSTransport) disconnected() {
+	wst.mutex.Lock()
+	defer wst.mutex.Unlock()
+	wst.connection = nil
+	wst.connection_ready = make(chan struct{})
+	for request_id, results := range wst.pending_results {
+		close(results)
+		delete(wst.pending_results, request_id)
+	}
+}
+
+func (wst *WSTransport) publishResult(result *PBBatchResult) {
+	wst.mutex.Lock()
+	defer wst.mutex.Unlock()
+	results, ok := wst.pending_results[result.GetRequestId()]
+	if !ok {
+		return
+	}
+	results <- result
+	delete(wst.pending_results, result.GetRequestId())
+}
+
+// Waits for the reader to have a live connection.
+func (wst *WSTransport) waitForConnection(ctx context.Context) (*websocket.Conn, error) {
+	for {
+		wst.mutex.Lock()
+		connection, ready := wst.connection, wst.connection_ready
+		wst.mutex.Unlock()
+		if connection != nil {
+			return connection, nil
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("not connected to the server")
+		}
+	}
+}
+
+func (wst *WSTransport) Writer(friend *FriendDetail, content []byte) error {
+	return wst.BatchWriter([]OutboundMessage{{friend: friend, content: content}})[0]
+}
+
+// Writes all the outbound messages to the websocket as a single
+// publish frame and waits for the server to report on each one.
+func (wst *WSTransport) BatchWriter(batch []OutboundMessage) []error {
+	request := newBatchRequest(batch)
+	result, err := wst.publish(request)
+	if err != nil {
+		errs := make([]error, len(batch))
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	return batchErrors(request, result)
+}
+
+func (wst *WSTransport) FanoutWriter(friends []*FriendDetail, content []byte) []error {
+	return fanout(wst.publish, wst.BatchWriter, friends, content)
+}
+
+// Sends the batch and waits for its result.
+func (wst *WSTransport) publish(request *PBBatchPublish) (*PBBatchResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), SESSION_PUBLISH_TIMEOUT)
+	defer cancel()
+	connection, err := wst.waitForConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make(chan *PBBatchResult, 1)
+	wst.mutex.Lock()
+	wst.next_request_id++
+	request.RequestId = wst.next_request_id
+	wst.pending_results[request.RequestId] = results
+	wst.mutex.Unlock()
+	defer func() {
+		wst.mutex.Lock()
+		delete(wst.pending_results, request.RequestId)
+		wst.mutex.Unlock()
+	}()
+
+	frame := &PBClientFrame{Frame: &PBClientFrame_Publish{Publish: request}}
+	data, err := proto.Marshal(frame)
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize publish frame... %w", err)
+	}
+	if wst.frameTooLarge(len(data)) {
+		return nil, errMessageTooLarge
+	}
+	err = connection.Write(ctx, websocket.MessageBinary, data)
+	if err != nil {
+		return nil, fmt.Errorf("could not write publish frame... %w", err)
+	}
+	select {
+	case result, ok := <-results:
+		if !ok {
+			return nil, fmt.Errorf("connection to the server dropped before it answered")
+		}
+		return result, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out waiting for the server to answer")
+	}
+}